@@ -0,0 +1,12 @@
+package cache
+
+import (
+	"github.com/Mueat/frm-lib/errors"
+	"github.com/go-redis/redis/v8"
+)
+
+// 注册redis错误对应的错误码，redis.Nil 对应 errors.ModelNotFound
+// 缓存未命中通常不是业务上的数据不存在，需要时手动调用
+func RegisterErrors() {
+	errors.Register(redis.Nil, errors.ModelNotFound)
+}
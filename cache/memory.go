@@ -0,0 +1,139 @@
+package cache
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 内存存储
+// 实现了Store接口，数据不会持久化，也不能跨进程共享
+type Memory struct {
+	mu    sync.Mutex
+	items map[string]memoryItem
+}
+
+type memoryItem struct {
+	value    string
+	expireAt time.Time
+}
+
+// 是否已经过期
+func (i memoryItem) expired(now time.Time) bool {
+	return !i.expireAt.IsZero() && !now.Before(i.expireAt)
+}
+
+// 创建内存存储
+func NewMemory() *Memory {
+	return &Memory{
+		items: make(map[string]memoryItem),
+	}
+}
+
+// 获取未过期的值，调用前需要加锁
+func (m *Memory) get(k string) (memoryItem, bool) {
+	item, ok := m.items[k]
+	if !ok {
+		return item, false
+	}
+	if item.expired(time.Now()) {
+		delete(m.items, k)
+		return item, false
+	}
+	return item, true
+}
+
+// 计算过期时间，ex小于等于0表示永不过期
+func expireAt(ex time.Duration) time.Time {
+	if ex <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(ex)
+}
+
+func (m *Memory) Set(k, v string, ex time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.items[k] = memoryItem{value: v, expireAt: expireAt(ex)}
+	return nil
+}
+
+func (m *Memory) GetString(k string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	item, _ := m.get(k)
+	return item.value
+}
+
+func (m *Memory) Get(k string) (string, error) {
+	return m.GetString(k), nil
+}
+
+func (m *Memory) Del(k string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.items, k)
+	return nil
+}
+
+func (m *Memory) DelIfEqual(k, v string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	item, ok := m.get(k)
+	if !ok || item.value != v {
+		return false, nil
+	}
+	delete(m.items, k)
+	return true, nil
+}
+
+func (m *Memory) SetNXEX(k, v string, ex time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.get(k); ok {
+		return false, nil
+	}
+	m.items[k] = memoryItem{value: v, expireAt: expireAt(ex)}
+	return true, nil
+}
+
+func (m *Memory) Expire(k string, ex time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	item, ok := m.get(k)
+	if !ok {
+		return nil
+	}
+	if ex <= 0 {
+		delete(m.items, k)
+		return nil
+	}
+	item.expireAt = expireAt(ex)
+	m.items[k] = item
+	return nil
+}
+
+func (m *Memory) Exists(key string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.get(key)
+	return ok, nil
+}
+
+func (m *Memory) Incr(key string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	item, _ := m.get(key)
+	var n int64
+	if item.value != "" {
+		v, err := strconv.ParseInt(item.value, 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		n = v
+	}
+	n++
+	item.value = strconv.FormatInt(n, 10)
+	m.items[key] = item
+	return n, nil
+}
@@ -13,14 +13,17 @@ import (
 )
 
 var (
-	ctx   = context.Background()
 	pools map[string]Pools
+
+	// 值相等时删除
+	delIfEqualScript = redis.NewScript(`if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("del", KEYS[1]) else return 0 end`)
 )
 
 type Pools struct {
 	Prefix string
 	Config RedisConfig
 	client *redis.Client
+	ctx    context.Context
 }
 
 type RedisConfig struct {
@@ -94,9 +97,28 @@ func (r *Pools) GetClient() *redis.Client {
 	return r.client
 }
 
+// 返回使用指定context的连接，请求取消或者超时后redis操作也会被取消
+func (r *Pools) WithContext(ctx context.Context) *Pools {
+	pool := *r
+	pool.ctx = ctx
+	return &pool
+}
+
+// 获取当前使用的context
+func (r *Pools) Context() context.Context {
+	return r.context()
+}
+
+func (r *Pools) context() context.Context {
+	if r.ctx == nil {
+		return context.Background()
+	}
+	return r.ctx
+}
+
 func (r *Pools) Set(k, v string, ex time.Duration) error {
 	k = r.GetKey(k)
-	err := r.client.Set(ctx, k, v, ex).Err()
+	err := r.client.Set(r.context(), k, v, ex).Err()
 	if err != nil {
 		log.Error().Err(err).Msgf("redis set error key: %s value : %s  error:%s", k, v, err)
 	}
@@ -105,7 +127,7 @@ func (r *Pools) Set(k, v string, ex time.Duration) error {
 
 func (r *Pools) Del(k string) error {
 	k = r.GetKey(k)
-	err := r.client.Del(ctx, k).Err()
+	err := r.client.Del(r.context(), k).Err()
 	if err != nil {
 		log.Error().Err(err).Msgf("redis Del error key: %v  error:%s", k, err)
 		return err
@@ -113,9 +135,20 @@ func (r *Pools) Del(k string) error {
 	return nil
 }
 
+// 值等于v时删除，用于释放自己持有的锁，避免删除锁过期后其他请求持有的锁
+func (r *Pools) DelIfEqual(k, v string) (bool, error) {
+	k = r.GetKey(k)
+	res, err := delIfEqualScript.Run(r.context(), r.client, []string{k}, v).Int64()
+	if err != nil {
+		log.Error().Err(err).Msgf("redis DelIfEqual error key: %s  error:%s", k, err)
+		return false, err
+	}
+	return res > 0, nil
+}
+
 func (r *Pools) SetNXEX(k, v string, ex time.Duration) (bool, error) {
 	k = r.GetKey(k)
-	res, err := r.client.SetNX(ctx, k, v, ex).Result()
+	res, err := r.client.SetNX(r.context(), k, v, ex).Result()
 	if err != nil {
 		log.Error().Err(err).Msgf("redis SetNXEX error key: %s value : %s  error:%s", k, v, err)
 		return false, err
@@ -124,7 +157,7 @@ func (r *Pools) SetNXEX(k, v string, ex time.Duration) (bool, error) {
 }
 func (r *Pools) Expire(k string, ex time.Duration) error {
 	k = r.GetKey(k)
-	err := r.client.Expire(ctx, k, ex).Err()
+	err := r.client.Expire(r.context(), k, ex).Err()
 	if err != nil {
 		if err != redis.Nil {
 			log.Error().Err(err).Msgf("redis Expire error key: %s ex : %s  error:%s", k, ex, err)
@@ -135,7 +168,7 @@ func (r *Pools) Expire(k string, ex time.Duration) error {
 }
 func (r *Pools) GetString(k string) string {
 	k = r.GetKey(k)
-	res, err := r.client.Get(ctx, k).Result()
+	res, err := r.client.Get(r.context(), k).Result()
 
 	if err != nil && err != redis.Nil {
 		log.Error().Err(err).Msgf("redis get error key: %s  error:%s", k, err.Error())
@@ -143,12 +176,25 @@ func (r *Pools) GetString(k string) string {
 	return res
 }
 
+// 获取字符串，key不存在时返回空字符串，与 GetString 不同的是会返回redis的错误
+func (r *Pools) Get(k string) (string, error) {
+	k = r.GetKey(k)
+	res, err := r.client.Get(r.context(), k).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	if err != nil {
+		log.Error().Err(err).Msgf("redis get error key: %s  error:%s", k, err.Error())
+	}
+	return res, err
+}
+
 func (r *Pools) BatchPushQueue(k string, values []string) (err error) {
 	if len(values) == 0 {
 		return
 	}
 	k = r.GetKey(k)
-	err = r.client.LPush(ctx, k, values).Err()
+	err = r.client.LPush(r.context(), k, values).Err()
 	if err != nil {
 		if err != redis.Nil {
 			log.Error().Err(err).Msgf("redis LPUSH key: %s value: %v error: %s", k, values, err)
@@ -159,7 +205,7 @@ func (r *Pools) BatchPushQueue(k string, values []string) (err error) {
 
 func (r *Pools) PopQueue(k string, timeout time.Duration) (data string, err error) {
 	k = r.GetKey(k)
-	nameAndData, err := r.client.BRPop(ctx, timeout, k).Result()
+	nameAndData, err := r.client.BRPop(r.context(), timeout, k).Result()
 	if err != nil {
 		if err != nil && err != redis.Nil {
 			log.Error().Err(err).Msgf("redis BRPOP queue queueName %s error %v ", k, err.Error())
@@ -174,7 +220,7 @@ func (r *Pools) PopQueue(k string, timeout time.Duration) (data string, err erro
 
 func (r *Pools) LPush(k string, v string) error {
 	k = r.GetKey(k)
-	err := r.client.LPush(ctx, k, v).Err()
+	err := r.client.LPush(r.context(), k, v).Err()
 	if err != nil {
 		log.Error().Err(err).Msgf("redis LPUSH key : %s value : %s error : %s", k, v, err.Error())
 		return err
@@ -184,7 +230,7 @@ func (r *Pools) LPush(k string, v string) error {
 
 func (r *Pools) HGet(key, field string) (string, error) {
 	key = r.GetKey(key)
-	res, err := r.client.HGet(ctx, key, field).Result()
+	res, err := r.client.HGet(r.context(), key, field).Result()
 	if err != nil {
 		if err != redis.Nil {
 			log.Error().Err(err).Msgf("redis HGET key : %s field : %s error : %s", key, field, err.Error())
@@ -196,7 +242,7 @@ func (r *Pools) HGet(key, field string) (string, error) {
 
 func (r *Pools) HGetAll(k string) (map[string]string, error) {
 	k = r.GetKey(k)
-	res, err := r.client.HGetAll(ctx, k).Result()
+	res, err := r.client.HGetAll(r.context(), k).Result()
 	if err != nil {
 		if err != redis.Nil {
 			log.Error().Err(err).Msgf("redis HGetAll key : %s  error : %v", k, err.Error())
@@ -208,7 +254,7 @@ func (r *Pools) HGetAll(k string) (map[string]string, error) {
 
 func (r *Pools) SMembers(key string) ([]string, error) {
 	key = r.GetKey(key)
-	res, err := r.client.SMembers(ctx, key).Result()
+	res, err := r.client.SMembers(r.context(), key).Result()
 	if err != nil {
 		if err != redis.Nil {
 			log.Error().Err(err).Msgf("redis HGetAll key : %s error : %v ", key, err.Error())
@@ -221,7 +267,7 @@ func (r *Pools) SMembers(key string) ([]string, error) {
 //hlen
 func (r *Pools) HLen(key string) (int64, error) {
 	key = r.GetKey(key)
-	res, err := r.client.HLen(ctx, key).Result()
+	res, err := r.client.HLen(r.context(), key).Result()
 	if err != nil {
 		if err != redis.Nil {
 			log.Error().Err(err).Msgf("redis HLen key : %s  error : %s", key, err.Error())
@@ -232,7 +278,7 @@ func (r *Pools) HLen(key string) (int64, error) {
 }
 func (r *Pools) HSet(key, field string, value string) error {
 	key = r.GetKey(key)
-	err := r.client.HSet(ctx, key, field, value).Err()
+	err := r.client.HSet(r.context(), key, field, value).Err()
 	if err != nil {
 		if err != redis.Nil {
 			log.Error().Err(err).Msgf("redis HSET key : %s  field : %s  value : %s error : %s", key, field, value, err.Error())
@@ -248,7 +294,7 @@ func (r *Pools) HSet(key, field string, value string) error {
 // If key does not exist, a new key holding a hash is created.
 func (r *Pools) HMSet(key string, values map[string]interface{}) error {
 	key = r.GetKey(key)
-	err := r.client.HMSet(ctx, key, values).Err()
+	err := r.client.HMSet(r.context(), key, values).Err()
 	if err != nil {
 		log.Error().Err(err).Msgf("redis HMSET key : %s   value : %v error : %s", key, values, err.Error())
 		return err
@@ -259,7 +305,7 @@ func (r *Pools) HMSet(key string, values map[string]interface{}) error {
 // HDel command:
 func (r *Pools) HDel(key string, fields []string) error {
 	key = r.GetKey(key)
-	err := r.client.HDel(ctx, key, fields...).Err()
+	err := r.client.HDel(r.context(), key, fields...).Err()
 	if err != nil {
 		log.Error().Err(err).Msgf("redis HDEL key : %s  fields : %v  error : %s", key, fields, err.Error())
 		return err
@@ -270,7 +316,7 @@ func (r *Pools) HDel(key string, fields []string) error {
 // zdd command:
 func (r *Pools) ZAdd(key string, score int64, member interface{}) error {
 	key = r.GetKey(key)
-	err := r.client.ZAdd(ctx, key, &redis.Z{Score: float64(score), Member: member}).Err()
+	err := r.client.ZAdd(r.context(), key, &redis.Z{Score: float64(score), Member: member}).Err()
 	if err != nil {
 		log.Error().Err(err).Msgf("redis ZAdd key : %s  score : %v  member : %v error : %s", key, score, member, err.Error())
 		return err
@@ -281,7 +327,7 @@ func (r *Pools) ZAdd(key string, score int64, member interface{}) error {
 // zdd command:
 func (r *Pools) Exists(key string) (bool, error) {
 	key = r.GetKey(key)
-	res, err := r.client.Exists(ctx, key).Result()
+	res, err := r.client.Exists(r.context(), key).Result()
 	if err != nil {
 		if err != redis.Nil {
 			log.Error().Err(err).Msgf("redis Exists key : %s  error : %s", key, err.Error())
@@ -297,7 +343,7 @@ func (r *Pools) Exists(key string) (bool, error) {
 //获取整个集合元素
 func (r *Pools) ZRangeAll(key string) ([]string, error) {
 	key = r.GetKey(key)
-	res, err := r.client.ZRange(ctx, key, 0, -1).Result()
+	res, err := r.client.ZRange(r.context(), key, 0, -1).Result()
 	if err != nil {
 		if err != redis.Nil {
 			log.Error().Err(err).Msgf("redis Exists key : %s  error : %s", key, err.Error())
@@ -310,7 +356,7 @@ func (r *Pools) ZRangeAll(key string) ([]string, error) {
 //删除集合元素
 func (r *Pools) ZRem(key string, members []string) error {
 	key = r.GetKey(key)
-	err := r.client.ZRem(ctx, key, members).Err()
+	err := r.client.ZRem(r.context(), key, members).Err()
 	if err != nil {
 		log.Error().Err(err).Msgf("redis Exists key : %s  error : %s", key, err.Error())
 		return err
@@ -321,7 +367,7 @@ func (r *Pools) ZRem(key string, members []string) error {
 //删除集合元素
 func (r *Pools) ZCard(key string) (int64, error) {
 	key = r.GetKey(key)
-	res, err := r.client.ZCard(ctx, key).Result()
+	res, err := r.client.ZCard(r.context(), key).Result()
 	if err != nil {
 		log.Error().Err(err).Msgf("redis ZCard key : %s  error : %s", key, err.Error())
 		return res, err
@@ -331,7 +377,7 @@ func (r *Pools) ZCard(key string) (int64, error) {
 
 func (r *Pools) Incr(key string) (int64, error) {
 	key = r.GetKey(key)
-	res, err := r.client.Incr(ctx, key).Result()
+	res, err := r.client.Incr(r.context(), key).Result()
 	if err != nil {
 		log.Error().Err(err).Msgf("redis ZCard key : %s  error : %s", key, err.Error())
 		return res, err
@@ -341,7 +387,7 @@ func (r *Pools) Incr(key string) (int64, error) {
 
 func (r *Pools) Decr(key string) (int64, error) {
 	key = r.GetKey(key)
-	res, err := r.client.Decr(ctx, key).Result()
+	res, err := r.client.Decr(r.context(), key).Result()
 	if err != nil {
 		log.Error().Err(err).Msgf("redis ZCard key : %s  error : %s", key, err.Error())
 		return res, err
@@ -352,7 +398,7 @@ func (r *Pools) Decr(key string) (int64, error) {
 // bitmap
 func (r *Pools) SetBit(key string, offset int64, val int) (int64, error) {
 	key = r.GetKey(key)
-	res, err := r.client.SetBit(ctx, key, offset, val).Result()
+	res, err := r.client.SetBit(r.context(), key, offset, val).Result()
 	if err != nil {
 		log.Error().Err(err).Msgf("redis SetBit key : %s  error : %s", key, err.Error())
 		return res, err
@@ -362,7 +408,7 @@ func (r *Pools) SetBit(key string, offset int64, val int) (int64, error) {
 
 func (r *Pools) GetBit(key string, offset int64) (int64, error) {
 	key = r.GetKey(key)
-	res, err := r.client.GetBit(ctx, key, offset).Result()
+	res, err := r.client.GetBit(r.context(), key, offset).Result()
 	if err != nil {
 		if err != redis.Nil {
 			log.Error().Err(err).Msgf("redis GetBit key : %s  error : %s", key, err.Error())
@@ -378,7 +424,7 @@ func (r *Pools) BitCount(key string, start int64, end int64) (int64, error) {
 		Start: start,
 		End:   end,
 	}
-	res, err := r.client.BitCount(ctx, key, &bc).Result()
+	res, err := r.client.BitCount(r.context(), key, &bc).Result()
 	if err != nil {
 		if err != redis.Nil {
 			log.Error().Err(err).Msgf("redis BitCount key : %s  error : %s", key, err.Error())
@@ -394,7 +440,7 @@ func (r *Pools) BitOpAnd(destKey string, keys ...string) (int64, error) {
 	for _, ky := range keys {
 		mkeys = append(mkeys, r.GetKey(ky))
 	}
-	res, err := r.client.BitOpAnd(ctx, destKey, mkeys...).Result()
+	res, err := r.client.BitOpAnd(r.context(), destKey, mkeys...).Result()
 	if err != nil {
 		if err != redis.Nil {
 			log.Error().Err(err).Msgf("redis BitOpAnd keys : %v  error : %s", keys, err.Error())
@@ -410,7 +456,7 @@ func (r *Pools) BitOpOr(destKey string, keys ...string) (int64, error) {
 	for _, ky := range keys {
 		mkeys = append(mkeys, r.GetKey(ky))
 	}
-	res, err := r.client.BitOpOr(ctx, destKey, mkeys...).Result()
+	res, err := r.client.BitOpOr(r.context(), destKey, mkeys...).Result()
 	if err != nil {
 		if err != redis.Nil {
 			log.Error().Err(err).Msgf("redis BitOpOr keys : %v  error : %s", keys, err.Error())
@@ -426,7 +472,7 @@ func (r *Pools) BitOpXor(destKey string, keys ...string) (int64, error) {
 	for _, ky := range keys {
 		mkeys = append(mkeys, r.GetKey(ky))
 	}
-	res, err := r.client.BitOpXor(ctx, destKey, mkeys...).Result()
+	res, err := r.client.BitOpXor(r.context(), destKey, mkeys...).Result()
 	if err != nil {
 		if err != redis.Nil {
 			log.Error().Err(err).Msgf("redis BitOpXor keys : %v  error : %s", keys, err.Error())
@@ -439,7 +485,7 @@ func (r *Pools) BitOpXor(destKey string, keys ...string) (int64, error) {
 func (r *Pools) BitOpNot(destKey string, key string) (int64, error) {
 	destKey = r.GetKey(destKey)
 	key = r.GetKey(key)
-	res, err := r.client.BitOpNot(ctx, destKey, key).Result()
+	res, err := r.client.BitOpNot(r.context(), destKey, key).Result()
 	if err != nil {
 		if err != redis.Nil {
 			log.Error().Err(err).Msgf("redis BitOpNot destkey: %s key : %s  error : %s", destKey, key, err.Error())
@@ -451,7 +497,7 @@ func (r *Pools) BitOpNot(destKey string, key string) (int64, error) {
 
 func (r *Pools) BitPos(key string, bit int64, pos ...int64) (int64, error) {
 	key = r.GetKey(key)
-	res, err := r.client.BitPos(ctx, key, bit, pos...).Result()
+	res, err := r.client.BitPos(r.context(), key, bit, pos...).Result()
 	if err != nil {
 		if err != redis.Nil {
 			log.Error().Err(err).Msgf("redis BitPos key : %s  error : %s", key, err.Error())
@@ -461,6 +507,25 @@ func (r *Pools) BitPos(key string, bit int64, pos ...int64) (int64, error) {
 	return res, nil
 }
 
+// 发布消息，频道名称会添加前缀
+func (r *Pools) Publish(channel string, message string) error {
+	channel = r.GetKey(channel)
+	err := r.client.Publish(r.context(), channel, message).Err()
+	if err != nil {
+		log.Error().Err(err).Msgf("redis Publish channel : %s  error : %s", channel, err.Error())
+	}
+	return err
+}
+
+// 订阅频道，频道名称会添加前缀，使用完成后需要调用Close
+func (r *Pools) Subscribe(channels ...string) *redis.PubSub {
+	keys := make([]string, len(channels))
+	for i, channel := range channels {
+		keys[i] = r.GetKey(channel)
+	}
+	return r.client.Subscribe(r.context(), keys...)
+}
+
 // Pipeline
 func (r *Pools) Pipeline() redis.Pipeliner {
 	return r.client.Pipeline()
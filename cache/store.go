@@ -0,0 +1,24 @@
+package cache
+
+import (
+	"time"
+)
+
+// Store 键值存储接口
+// *Pools 基于redis实现，*Memory 基于内存实现，主要用于测试或者单机场景
+type Store interface {
+	Set(k, v string, ex time.Duration) error
+	GetString(k string) string
+	Get(k string) (string, error)
+	Del(k string) error
+	DelIfEqual(k, v string) (bool, error)
+	SetNXEX(k, v string, ex time.Duration) (bool, error)
+	Expire(k string, ex time.Duration) error
+	Exists(key string) (bool, error)
+	Incr(key string) (int64, error)
+}
+
+var (
+	_ Store = (*Pools)(nil)
+	_ Store = (*Memory)(nil)
+)
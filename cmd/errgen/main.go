@@ -0,0 +1,86 @@
+// errgen 根据错误码常量定义生成错误信息字典，以及JSON、TypeScript和Markdown文档
+//
+//	//go:generate go run github.com/Mueat/frm-lib/cmd/errgen -src . -go errors_map.go -md ../docs/errors.md
+//	errgen -src ./errors/user.go,./errors/order.go -go ./codes/codes.go -pkg codes -range user=10000-19999,order=20000-29999
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Mueat/frm-lib/errors"
+)
+
+func main() {
+	src := flag.String("src", ".", "错误定义文件或目录，多个用逗号分隔")
+	goFile := flag.String("go", "", "生成的Go文件")
+	pkg := flag.String("pkg", "", "生成的Go文件的包名，默认与错误定义的包名相同")
+	varName := flag.String("var", "Errors", "错误信息map的变量名")
+	register := flag.Bool("register", false, "生成的Go文件中通过 errors.AddErrors 注册错误信息")
+	jsonFile := flag.String("json", "", "生成的JSON文件")
+	tsFile := flag.String("ts", "", "生成的TypeScript文件")
+	mdFile := flag.String("md", "", "生成的Markdown文件")
+	ranges := flag.String("range", "", "模块的错误码范围，如 user=10000-19999,order=20000-29999")
+	flag.Parse()
+
+	codeRanges, err := parseRanges(*ranges)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "errgen:", err)
+		os.Exit(2)
+	}
+	defs, err := errors.Generate(errors.GenConfig{
+		Sources:  splitList(*src),
+		GoFile:   *goFile,
+		Package:  *pkg,
+		VarName:  *varName,
+		Register: *register,
+		JSONFile: *jsonFile,
+		TSFile:   *tsFile,
+		MDFile:   *mdFile,
+		Ranges:   codeRanges,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "errgen:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("errgen: %d error codes\n", len(defs))
+}
+
+// 解析逗号分隔的列表
+func splitList(s string) []string {
+	res := make([]string, 0)
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+// 解析模块的错误码范围，格式：module=min-max
+func parseRanges(s string) (map[string]errors.CodeRange, error) {
+	res := make(map[string]errors.CodeRange)
+	for _, item := range splitList(s) {
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid range %q", item)
+		}
+		bounds := strings.SplitN(kv[1], "-", 2)
+		if len(bounds) != 2 {
+			return nil, fmt.Errorf("invalid range %q", item)
+		}
+		min, err := strconv.Atoi(strings.TrimSpace(bounds[0]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid range %q", item)
+		}
+		max, err := strconv.Atoi(strings.TrimSpace(bounds[1]))
+		if err != nil || max < min {
+			return nil, fmt.Errorf("invalid range %q", item)
+		}
+		res[strings.TrimSpace(kv[0])] = errors.CodeRange{Min: min, Max: max}
+	}
+	return res, nil
+}
@@ -2,12 +2,20 @@ package curl
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"mime/multipart"
 	"net/http"
 	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 
+	"github.com/Mueat/frm-lib/log"
+	"github.com/Mueat/frm-lib/trace"
 	"github.com/Mueat/frm-lib/util"
 	"github.com/ddliu/go-httpclient"
 )
@@ -30,10 +38,15 @@ type Opts struct {
 	Retry uint
 	// 是否开启Debug
 	Debug bool
+	// 请求签名的调用方ID，设置后每个请求都会使用 util.SignHTTPRequest 签名
+	SignAppID string
+	// 请求签名的调用方秘钥
+	SignSecret string
 }
 
 type Client struct {
-	Options    Opts
+	Options Opts
+	// Deprecated: 重试次数在每次请求中单独计算，不再使用该字段
 	Tried      uint
 	HttpClient *httpclient.HttpClient
 }
@@ -42,13 +55,13 @@ type Client struct {
 func New(options Opts) *Client {
 	hmap := httpclient.Map{}
 	if options.ConnectTimeout > 0 {
-		hmap[httpclient.OPT_CONNECTTIMEOUT] = options.ConnectTimeout
+		hmap[httpclient.OPT_CONNECTTIMEOUT] = int(options.ConnectTimeout)
 	} else {
 		hmap[httpclient.OPT_CONNECTTIMEOUT] = DefaultConnectTimeout
 	}
 
 	if options.Timeout > 0 {
-		hmap[httpclient.OPT_TIMEOUT] = options.Timeout
+		hmap[httpclient.OPT_TIMEOUT] = int(options.Timeout)
 	} else {
 		hmap[httpclient.OPT_TIMEOUT] = DefaultTimeout
 	}
@@ -57,7 +70,18 @@ func New(options Opts) *Client {
 		hmap[httpclient.OPT_USERAGENT] = options.UserAgent
 	}
 
-	if options.BeforeRequestFun != nil {
+	if options.SignAppID != "" {
+		before := options.BeforeRequestFun
+		hmap[httpclient.OPT_BEFORE_REQUEST_FUNC] = func(hc *http.Client, req *http.Request) {
+			if before != nil {
+				before(hc, req)
+			}
+			// 签名放在最后，保证签名的是最终发送的请求
+			if err := util.SignHTTPRequest(req, options.SignAppID, options.SignSecret); err != nil {
+				log.Error().Err(err).Str("type", "CURL").Str("name", "client").Str("method", "Sign").Str("url", log.MaskText(req.URL.String())).Send()
+			}
+		}
+	} else if options.BeforeRequestFun != nil {
 		hmap[httpclient.OPT_BEFORE_REQUEST_FUNC] = options.BeforeRequestFun
 	}
 
@@ -68,66 +92,152 @@ func New(options Opts) *Client {
 	client := Client{}
 	client.HttpClient = httpclient.NewHttpClient().Defaults(hmap)
 	client.Options = options
-	client.Tried = 0
 	return &client
 }
 
 // 发起http请求
 func (c *Client) Do(method string, url string, data interface{}, headers map[string]string) (*httpclient.Response, error) {
-	c.Tried++
+	return c.DoContext(context.Background(), method, url, data, headers)
+}
+
+// 使用context发起http请求，context取消后请求也会被取消，context中的请求ID会通过请求头传递
+// 可以在多个goroutine中同时使用同一个Client
+func (c *Client) DoContext(ctx context.Context, method string, url string, data interface{}, headers map[string]string) (*httpclient.Response, error) {
+	var tried uint
+	for {
+		tried++
+		resp, err := c.do(ctx, method, url, data, headers)
+		if err == nil && resp.StatusCode < 500 {
+			return resp, nil
+		}
+		if c.Options.Retry <= tried || ctx.Err() != nil {
+			return resp, err
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+	}
+}
+
+// 发起一次请求
+// 请求体在 Begin 之前准备好，Begin 加锁后只调用 Do，Do 在发出请求前会释放锁，避免出错时锁无法释放
+func (c *Client) do(ctx context.Context, method string, url string, data interface{}, headers map[string]string) (*httpclient.Response, error) {
 	isJson := false
 	for k, v := range headers {
 		if util.Strtoupper(k) == "CONTENT-TYPE" && util.Stripos(v, "application/json", 0) > -1 {
 			isJson = true
 		}
 	}
-	hc := c.HttpClient.WithHeaders(headers)
 
-	var resp *httpclient.Response
-	var err error
-	if util.Strtoupper(method) == http.MethodPost {
-		if isJson {
-			resp, err = hc.PostJson(url, data)
-		} else {
-			resp, err = hc.Post(url, toUrlValues(data))
-		}
-	}
-	if util.Strtoupper(method) == http.MethodPut {
-		if isJson {
-			resp, err = hc.PutJson(url, data)
+	method = util.Strtoupper(method)
+	reqHeaders := make(map[string]string)
+	var body io.Reader
+	switch method {
+	case http.MethodGet, http.MethodDelete:
+		url = addParams(url, toUrlValues(data))
+	case http.MethodPost, http.MethodPut:
+		if isJson || method == http.MethodPut {
+			b, err := jsonBody(data)
+			if err != nil {
+				return nil, err
+			}
+			body = bytes.NewReader(b)
 		} else {
-			var body []byte
-			switch t := data.(type) {
-			case []byte:
-				body = t
-			case string:
-				body = []byte(t)
-			default:
-				var err error
-				body, err = json.Marshal(data)
+			values := toUrlValues(data)
+			if hasFormFile(values) {
+				b, contentType, err := multipartBody(values)
 				if err != nil {
 					return nil, err
 				}
+				body = b
+				reqHeaders["Content-Type"] = contentType
+			} else {
+				body = strings.NewReader(values.Encode())
+				reqHeaders["Content-Type"] = "application/x-www-form-urlencoded"
 			}
-
-			resp, err = hc.Do(http.MethodPut, url, headers, bytes.NewReader(body))
 		}
-	} else if util.Strtoupper(method) == http.MethodGet {
-		resp, err = hc.Get(url, toUrlValues(data))
-	} else if util.Strtoupper(method) == http.MethodDelete {
-		resp, err = hc.Delete(url, toUrlValues(data))
+	default:
+		return nil, fmt.Errorf("curl: unsupported method %s", method)
+	}
+	for k, v := range headers {
+		reqHeaders[k] = v
+	}
+	if requestID := trace.FromContext(ctx); requestID != "" {
+		reqHeaders[trace.HeaderName] = requestID
 	}
 
-	if err != nil {
-		if c.Options.Retry > c.Tried {
-			return c.Do(method, url, data, headers)
+	// 本次请求的选项保存在共享的HttpClient中，需要加锁避免并发请求互相覆盖
+	return c.HttpClient.Begin().WithOption(httpclient.OPT_CONTEXT, ctx).Do(method, url, reqHeaders, body)
+}
+
+// JSON请求体
+func jsonBody(data interface{}) ([]byte, error) {
+	switch t := data.(type) {
+	case []byte:
+		return t, nil
+	case string:
+		return []byte(t), nil
+	}
+	return json.Marshal(data)
+}
+
+// 添加url参数
+func addParams(u string, params url.Values) string {
+	if len(params) == 0 {
+		return u
+	}
+	if !strings.Contains(u, "?") {
+		return u + "?" + params.Encode()
+	}
+	if strings.HasSuffix(u, "?") || strings.HasSuffix(u, "&") {
+		return u + params.Encode()
+	}
+	return u + "&" + params.Encode()
+}
+
+// 以 @ 开头的参数为上传的文件路径
+func hasFormFile(values url.Values) bool {
+	for k := range values {
+		if strings.HasPrefix(k, "@") {
+			return true
 		}
-	} else if resp.StatusCode >= 500 {
-		if c.Options.Retry > c.Tried {
-			return c.Do(method, url, data, headers)
+	}
+	return false
+}
+
+// multipart/form-data 请求体
+func multipartBody(values url.Values) (io.Reader, string, error) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for k, vs := range values {
+		for _, v := range vs {
+			if !strings.HasPrefix(k, "@") {
+				writer.WriteField(k, v)
+				continue
+			}
+			if err := addFormFile(writer, k[1:], v); err != nil {
+				return nil, "", err
+			}
 		}
 	}
-	return resp, err
+	if err := writer.Close(); err != nil {
+		return nil, "", err
+	}
+	return body, writer.FormDataContentType(), nil
+}
+
+func addFormFile(writer *multipart.Writer, name, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	part, err := writer.CreateFormFile(name, filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(part, file)
+	return err
 }
 
 func BindResponse(resp *httpclient.Response, bindData interface{}) error {
@@ -0,0 +1,18 @@
+package curl
+
+import (
+	"net"
+
+	"github.com/Mueat/frm-lib/errors"
+)
+
+// 注册请求超时对应的错误码
+func init() {
+	errors.RegisterMatcher(func(err error) (int, bool) {
+		var ne net.Error
+		if errors.As(err, &ne) && ne.Timeout() {
+			return errors.GatewayTimeout, true
+		}
+		return 0, false
+	})
+}
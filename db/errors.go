@@ -0,0 +1,11 @@
+package db
+
+import (
+	"github.com/Mueat/frm-lib/errors"
+	"gorm.io/gorm"
+)
+
+// 注册数据库错误对应的错误码
+func init() {
+	errors.Register(gorm.ErrRecordNotFound, errors.ModelNotFound)
+}
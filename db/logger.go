@@ -1,11 +1,79 @@
 package db
 
-import "github.com/Mueat/frm-lib/log"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
 
-// 日志记录
+	"github.com/Mueat/frm-lib/log"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/utils"
+)
+
+// 日志记录，sql中的敏感数据会脱敏
 type DBLogger struct {
 }
 
 func (l DBLogger) Printf(format string, args ...interface{}) {
-	log.Info().Str("type", "SQL").Msgf(format, args...)
+	log.Info().Str("type", "SQL").Msg(log.MaskText(fmt.Sprintf(format, args...)))
+}
+
+// 支持context的sql日志，会记录context中的请求ID，请求开启debug日志时记录全部sql
+type ContextLogger struct {
+	SlowThreshold time.Duration
+	LogLevel      logger.LogLevel
+}
+
+// 创建sql日志
+func NewLogger(slowThreshold time.Duration, level logger.LogLevel) logger.Interface {
+	return &ContextLogger{SlowThreshold: slowThreshold, LogLevel: level}
+}
+
+func (l *ContextLogger) LogMode(level logger.LogLevel) logger.Interface {
+	nl := *l
+	nl.LogLevel = level
+	return &nl
+}
+
+func (l *ContextLogger) Info(ctx context.Context, msg string, data ...interface{}) {
+	if l.LogLevel >= logger.Info || log.IsDebug(ctx) {
+		log.Ctx(ctx, "").Info().Str("type", "SQL").Str("file", utils.FileWithLineNum()).Msg(log.MaskText(fmt.Sprintf(msg, data...)))
+	}
+}
+
+func (l *ContextLogger) Warn(ctx context.Context, msg string, data ...interface{}) {
+	if l.LogLevel >= logger.Warn {
+		log.Ctx(ctx, "").Warn().Str("type", "SQL").Str("file", utils.FileWithLineNum()).Msg(log.MaskText(fmt.Sprintf(msg, data...)))
+	}
+}
+
+func (l *ContextLogger) Error(ctx context.Context, msg string, data ...interface{}) {
+	if l.LogLevel >= logger.Error {
+		log.Ctx(ctx, "").Error().Str("type", "SQL").Str("file", utils.FileWithLineNum()).Msg(log.MaskText(fmt.Sprintf(msg, data...)))
+	}
+}
+
+func (l *ContextLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	// 请求开启debug日志时即使配置为Silent也记录sql
+	debug := log.IsDebug(ctx)
+	if l.LogLevel <= logger.Silent && !debug {
+		return
+	}
+	elapsed := time.Since(begin)
+	switch {
+	case err != nil && l.LogLevel >= logger.Error && !errors.Is(err, gorm.ErrRecordNotFound):
+		sql, rows := fc()
+		log.Ctx(ctx, "").Error().Err(err).Str("type", "SQL").Str("file", utils.FileWithLineNum()).
+			Dur("elapsed", elapsed).Int64("rows", rows).Str("sql", log.MaskText(sql)).Send()
+	case elapsed > l.SlowThreshold && l.SlowThreshold != 0 && l.LogLevel >= logger.Warn:
+		sql, rows := fc()
+		log.Ctx(ctx, "").Warn().Str("type", "SQL").Str("file", utils.FileWithLineNum()).
+			Dur("elapsed", elapsed).Int64("rows", rows).Str("sql", log.MaskText(sql)).Msg(fmt.Sprintf("SLOW SQL >= %v", l.SlowThreshold))
+	case l.LogLevel == logger.Info || debug:
+		sql, rows := fc()
+		log.Ctx(ctx, "").Info().Err(err).Str("type", "SQL").Str("file", utils.FileWithLineNum()).
+			Dur("elapsed", elapsed).Int64("rows", rows).Str("sql", log.MaskText(sql)).Send()
+	}
 }
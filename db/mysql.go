@@ -37,13 +37,8 @@ func ConnectMysql(confs map[string]MysqlConfig) {
 	mysqlConnections = make(map[string]*gorm.DB)
 	connectOnce.Do(func() {
 		for k, conf := range configs {
-			newLogger := logger.New(
-				DBLogger{}, // io writer
-				logger.Config{
-					SlowThreshold: time.Duration(conf.SlowThreshold) * time.Millisecond, // 慢 SQL 阈值
-					LogLevel:      logger.LogLevel(conf.LogLevel),                       // Log level
-				},
-			)
+			// 慢 SQL 阈值和日志等级，日志中会记录context中的请求ID
+			newLogger := NewLogger(time.Duration(conf.SlowThreshold)*time.Millisecond, logger.LogLevel(conf.LogLevel))
 			dns := fmt.Sprintf("%s:%s@tcp(%s)/%s?parseTime=true&charset=%s&loc=%s", conf.Username, conf.Password, conf.Host, conf.DBName, conf.Charset, url.QueryEscape(conf.Location))
 			db, err := gorm.Open(mysql.Open(dns), &gorm.Config{
 				Logger: newLogger,
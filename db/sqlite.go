@@ -28,13 +28,8 @@ func ConnectSqlite(confs map[string]SqliteConfig) {
 	sqliteConnections = make(map[string]*gorm.DB)
 	connectOnce.Do(func() {
 		for k, conf := range sqliteConfigs {
-			newLogger := logger.New(
-				DBLogger{}, // io writer
-				logger.Config{
-					SlowThreshold: time.Duration(conf.SlowThreshold) * time.Millisecond, // 慢 SQL 阈值
-					LogLevel:      logger.LogLevel(conf.LogLevel),                       // Log level
-				},
-			)
+			// 慢 SQL 阈值和日志等级，日志中会记录context中的请求ID
+			newLogger := NewLogger(time.Duration(conf.SlowThreshold)*time.Millisecond, logger.LogLevel(conf.LogLevel))
 			db, err := gorm.Open(sqlite.Open(conf.DBPath), &gorm.Config{
 				Logger: newLogger,
 			})
@@ -2,62 +2,219 @@ package errors
 
 import (
 	"encoding/json"
+	stderrors "errors"
 	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog"
 )
 
+// 最多记录的调用栈层数
+const maxStackDepth = 32
+
 type Err struct {
 	File string `json:"file"`
 	Func string `json:"func"`
 	Line int    `json:"line"`
 	Code int    `json:"code"`
 	Msg  string `json:"error"`
+	// 错误信息中占位符的参数，如 {"field": "name", "min": 2}
+	Params map[string]interface{} `json:"-"`
+	// 附加的上下文信息，只记录到日志中，不会返回给客户端
+	Fields map[string]interface{} `json:"fields,omitempty"`
+
+	cause error
+	stack []uintptr
 }
 
 func (e Err) Error() string {
-	eb, err := json.Marshal(e)
+	type errJSON Err
+	v := struct {
+		errJSON
+		Cause string `json:"cause,omitempty"`
+	}{errJSON: errJSON(e)}
+	if e.cause != nil {
+		v.Cause = e.cause.Error()
+	}
+	eb, err := json.Marshal(v)
 	if err != nil {
 		return err.Error()
 	}
 	return string(eb)
 }
 
+// 返回被包装的错误，用于 errors.Is 和 errors.As
+func (e *Err) Unwrap() error {
+	return e.cause
+}
+
+// 返回被包装的错误
+func (e *Err) Cause() error {
+	return e.cause
+}
+
+// 错误码相同时认为是同一个错误，如 errors.Is(err, errors.Code(errors.NotFound))
+func (e *Err) Is(target error) bool {
+	t, ok := target.(*Err)
+	return ok && t.Code != OK && t.Code == e.Code
+}
+
+// 设置错误信息中占位符的参数
+func (e *Err) WithParams(params map[string]interface{}) *Err {
+	e.Params = params
+	return e
+}
+
+// 附加上下文信息，如订单号、用户ID，只记录到日志中
+func (e *Err) With(key string, value interface{}) *Err {
+	if e.Fields == nil {
+		e.Fields = make(map[string]interface{})
+	}
+	e.Fields[key] = value
+	return e
+}
+
+// 设置被包装的错误
+func (e *Err) WithCause(err error) *Err {
+	e.cause = err
+	return e
+}
+
+// 错误产生时的调用栈，每行一个调用
+func (e *Err) StackTrace() string {
+	if len(e.stack) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	frames := runtime.CallersFrames(e.stack)
+	for {
+		frame, more := frames.Next()
+		b.WriteString(frame.Function)
+		b.WriteString("\n\t")
+		b.WriteString(frame.File)
+		b.WriteString(":")
+		b.WriteString(strconv.Itoa(frame.Line))
+		b.WriteString("\n")
+		if !more {
+			break
+		}
+	}
+	return b.String()
+}
+
+// 记录到zerolog日志中，包括被包装的错误链和调用栈
+//
+//	log.Error().Err(err).Send()
+func (e *Err) MarshalZerologObject(ev *zerolog.Event) {
+	ev.Int("code", e.Code).Str("msg", e.Msg).Str("file", e.File).Str("func", e.Func).Int("line", e.Line)
+	if len(e.Fields) > 0 {
+		ev.Dict("fields", zerolog.Dict().Fields(e.Fields))
+	}
+	if e.cause != nil {
+		ev.Strs("causes", causeChain(e.cause))
+	}
+	if stack := e.StackTrace(); stack != "" {
+		ev.Str("stack", stack)
+	}
+}
+
+// 错误链中每个错误的信息
+func causeChain(err error) []string {
+	chain := make([]string, 0)
+	for err != nil {
+		if e, ok := err.(*Err); ok {
+			chain = append(chain, e.Msg)
+		} else {
+			chain = append(chain, err.Error())
+		}
+		err = stderrors.Unwrap(err)
+	}
+	return chain
+}
+
+// 创建错误并记录调用栈
+// @param int skip 跳过的调用层数，0表示调用newErr的函数
+func newErr(skip int) *Err {
+	e := &Err{}
+	pcs := make([]uintptr, maxStackDepth)
+	n := runtime.Callers(skip+2, pcs)
+	e.stack = pcs[:n]
+	if n > 0 {
+		frame, _ := runtime.CallersFrames(e.stack).Next()
+		e.File = frame.File
+		e.Func = frame.Function
+		e.Line = frame.Line
+	}
+	return e
+}
+
+// 包装错误，错误信息使用err的信息，err为nil时返回nil
+// 错误码根据注册的对应关系设置，未匹配时使用 System
 func New(err error) *Err {
-	pc, file, line, ok := runtime.Caller(1)
-	e := Err{}
-	if ok {
-		f := runtime.FuncForPC(pc)
-		e.File = file
-		e.Func = f.Name()
-		e.Line = line
+	if err == nil {
+		return nil
+	}
+	e := newErr(1)
+	code, ok := Lookup(err)
+	if !ok {
+		code = System
 	}
+	e.Code = code
 	e.Msg = err.Error()
-	return &e
+	e.cause = err
+	return e
 }
 
-func Code(code int) *Err {
-	pc, file, line, ok := runtime.Caller(1)
-	e := Err{}
-	if ok {
-		f := runtime.FuncForPC(pc)
-		e.File = file
-		e.Func = f.Name()
-		e.Line = line
+// 包装错误并设置错误码，错误信息使用错误码对应的信息，err为nil时返回nil
+//
+//	if err := db.First(&user).Error; err != nil {
+//		return nil, errors.Wrap(err, errors.ModelNotFound)
+//	}
+func Wrap(err error, code int) *Err {
+	if err == nil {
+		return nil
 	}
+	e := newErr(1)
 	e.Code = code
 	e.Msg = GetErrorMsg(code)
-	return &e
+	e.cause = err
+	return e
+}
+
+func Code(code int) *Err {
+	e := newErr(1)
+	e.Code = code
+	e.Msg = GetErrorMsg(code)
+	return e
+}
+
+// 创建指定错误码和错误信息的错误
+func CodeMsg(code int, msg string) *Err {
+	e := newErr(1)
+	e.Code = code
+	e.Msg = msg
+	return e
 }
 
 func Msg(msg string) *Err {
-	pc, file, line, ok := runtime.Caller(1)
-	e := Err{}
-	if ok {
-		f := runtime.FuncForPC(pc)
-		e.File = file
-		e.Func = f.Name()
-		e.Line = line
-	}
+	e := newErr(1)
 	e.Code = System
 	e.Msg = msg
-	return &e
+	return e
+}
+
+// 与标准库的 errors.Is 相同
+func Is(err, target error) bool {
+	return stderrors.Is(err, target)
+}
+
+// 与标准库的 errors.As 相同
+func As(err error, target interface{}) bool {
+	return stderrors.As(err, target)
+}
+
+// 与标准库的 errors.Unwrap 相同
+func Unwrap(err error) error {
+	return stderrors.Unwrap(err)
 }
@@ -15,8 +15,14 @@ const (
 	Forbidden = 403
 	// Not Found
 	NotFound = 404
+	// Conflict
+	Conflict = 409
+	// Payload Too Large
+	PayloadTooLarge = 413
 	// Internal Server Error
 	InternalServerError = 500
+	// Gateway Timeout
+	GatewayTimeout = 504
 )
 
 var Errors = map[int]string{
@@ -27,7 +33,10 @@ var Errors = map[int]string{
 	Unauthorized:        "Unauthorized",
 	Forbidden:           "Forbidden",
 	NotFound:            "Not Found",
+	Conflict:            "Conflict",
+	PayloadTooLarge:     "Payload Too Large",
 	InternalServerError: "Internal Server Error",
+	GatewayTimeout:      "Gateway Timeout",
 }
 
 func GetErrorMsg(code int) string {
@@ -0,0 +1,477 @@
+package errors
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/format"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 生成文件的头部注释
+const genHeader = "// Code generated by errgen. DO NOT EDIT."
+
+// 常量组注释中指定模块的标记，如：// @module user
+const moduleTag = "@module"
+
+var generatedRe = regexp.MustCompile(`^// Code generated .* DO NOT EDIT\.$`)
+
+// 错误码范围，包含 Min 和 Max
+type CodeRange struct {
+	Min int
+	Max int
+}
+
+// 错误码定义
+type ErrorDef struct {
+	Code   int    `json:"code"`
+	Name   string `json:"name,omitempty"`
+	Msg    string `json:"msg"`
+	Module string `json:"module,omitempty"`
+	Pos    string `json:"-"` // 定义的位置，file:line
+
+	typed bool // 是否为类型常量
+}
+
+// 错误码生成配置
+type GenConfig struct {
+	Sources  []string             // 错误定义文件或目录，目录中除测试文件和生成文件外的所有go文件
+	GoFile   string               // 生成的Go文件，为空时不生成
+	Package  string               // 生成的Go文件的包名，默认与错误定义的包名相同
+	VarName  string               // 错误信息map的变量名，默认：Errors
+	Register bool                 // 生成的Go文件中是否通过 errors.AddErrors 注册错误信息
+	JSONFile string               // 生成的JSON文件，为空时不生成
+	TSFile   string               // 生成的TypeScript文件，为空时不生成
+	MDFile   string               // 生成的Markdown文件，为空时不生成
+	Ranges   map[string]CodeRange // 每个模块的错误码范围，模块默认为文件名，可以在常量组注释中通过 @module 指定
+}
+
+// 根据错误定义生成Go、JSON、TypeScript和Markdown文件
+//
+// 错误信息依次取常量的注释、行尾注释、只有一个常量时常量组的注释，都没有时使用常量名，支持iota和类型常量
+//
+//	// @module user
+//	const (
+//		UserNotFound ErrCode = iota + 10000 // 用户不存在
+//		// 密码错误
+//		PasswordWrong
+//	)
+func Generate(conf GenConfig) ([]ErrorDef, error) {
+	if conf.VarName == "" {
+		conf.VarName = "Errors"
+	}
+	exclude := []string{conf.GoFile}
+	defs, pkgName, err := ParseErrorDefs(conf.Sources, exclude...)
+	if err != nil {
+		return nil, err
+	}
+	if err := CheckErrorDefs(defs, conf.Ranges); err != nil {
+		return nil, err
+	}
+	if conf.GoFile != "" {
+		if conf.Package == "" {
+			conf.Package = pkgName
+		}
+		src, err := genGoFile(defs, conf, sameDir(conf.GoFile, conf.Sources))
+		if err != nil {
+			return nil, err
+		}
+		if err := writeStrToFile(string(src), conf.GoFile); err != nil {
+			return nil, err
+		}
+	}
+	if conf.JSONFile != "" {
+		if err := WriteErrorsJSON(defs, conf.JSONFile); err != nil {
+			return nil, err
+		}
+	}
+	if conf.TSFile != "" {
+		if err := WriteErrorsTS(defs, conf.TSFile); err != nil {
+			return nil, err
+		}
+	}
+	if conf.MDFile != "" {
+		if err := WriteErrorsMarkdown(defs, conf.MDFile); err != nil {
+			return nil, err
+		}
+	}
+	return defs, nil
+}
+
+// 解析错误定义，返回按错误码排序的定义和包名
+// 同一目录的文件作为一个包进行类型检查，以便计算iota和引用其他常量的值
+// @param []string sources 错误定义文件或目录
+// @param []string exclude 需要跳过的文件
+func ParseErrorDefs(sources []string, exclude ...string) ([]ErrorDef, string, error) {
+	skip := make(map[string]bool)
+	for _, f := range exclude {
+		if f == "" {
+			continue
+		}
+		if abs, err := filepath.Abs(f); err == nil {
+			skip[abs] = true
+		}
+	}
+
+	// 按目录分组
+	dirs := make([]string, 0)
+	files := make(map[string][]string)
+	for _, src := range sources {
+		info, err := os.Stat(src)
+		if err != nil {
+			return nil, "", err
+		}
+		var list []string
+		if info.IsDir() {
+			matches, err := filepath.Glob(filepath.Join(src, "*.go"))
+			if err != nil {
+				return nil, "", err
+			}
+			for _, m := range matches {
+				if !strings.HasSuffix(m, "_test.go") {
+					list = append(list, m)
+				}
+			}
+		} else {
+			list = []string{src}
+		}
+		for _, f := range list {
+			abs, err := filepath.Abs(f)
+			if err != nil {
+				return nil, "", err
+			}
+			if skip[abs] {
+				continue
+			}
+			dir := filepath.Dir(abs)
+			if _, ok := files[dir]; !ok {
+				dirs = append(dirs, dir)
+			}
+			files[dir] = append(files[dir], abs)
+		}
+	}
+
+	defs := make([]ErrorDef, 0)
+	pkgName := ""
+	for _, dir := range dirs {
+		pkgDefs, name, err := parsePackageDefs(files[dir])
+		if err != nil {
+			return nil, "", err
+		}
+		if pkgName == "" {
+			pkgName = name
+		}
+		defs = append(defs, pkgDefs...)
+	}
+	sort.SliceStable(defs, func(i, j int) bool {
+		return defs[i].Code < defs[j].Code
+	})
+	return defs, pkgName, nil
+}
+
+// 解析同一个包中的错误定义
+func parsePackageDefs(paths []string) ([]ErrorDef, string, error) {
+	fset := token.NewFileSet()
+	files := make([]*ast.File, 0, len(paths))
+	for _, p := range paths {
+		f, err := parser.ParseFile(fset, p, nil, parser.ParseComments)
+		if err != nil {
+			return nil, "", err
+		}
+		if isGenerated(f) {
+			continue
+		}
+		files = append(files, f)
+	}
+	if len(files) == 0 {
+		return nil, "", nil
+	}
+
+	// 类型检查时忽略错误，只有常量的值无法计算时才返回错误
+	var typeErr error
+	conf := types.Config{
+		Importer: importer.Default(),
+		Error: func(err error) {
+			if typeErr == nil {
+				typeErr = err
+			}
+		},
+	}
+	info := &types.Info{Defs: make(map[*ast.Ident]types.Object)}
+	conf.Check(files[0].Name.Name, fset, files, info)
+
+	defs := make([]ErrorDef, 0)
+	for _, f := range files {
+		module := strings.TrimSuffix(filepath.Base(fset.Position(f.Pos()).Filename), ".go")
+		for _, d := range f.Decls {
+			decl, ok := d.(*ast.GenDecl)
+			if !ok || decl.Tok != token.CONST {
+				continue
+			}
+			groupMsg, groupModule := parseComment(decl.Doc)
+			if groupModule == "" {
+				groupModule = module
+			}
+			for _, s := range decl.Specs {
+				spec := s.(*ast.ValueSpec)
+				for _, ident := range spec.Names {
+					if ident.Name == "_" || !ident.IsExported() {
+						continue
+					}
+					obj, ok := info.Defs[ident].(*types.Const)
+					if !ok {
+						continue
+					}
+					pos := fset.Position(ident.Pos())
+					val := obj.Val()
+					if val.Kind() == constant.Unknown {
+						if typeErr != nil {
+							return nil, "", fmt.Errorf("%s: cannot evaluate %s: %v", pos, ident.Name, typeErr)
+						}
+						return nil, "", fmt.Errorf("%s: cannot evaluate %s", pos, ident.Name)
+					}
+					if val.Kind() != constant.Int {
+						continue
+					}
+					code, exact := constant.Int64Val(val)
+					if !exact {
+						return nil, "", fmt.Errorf("%s: %s overflows int64", pos, ident.Name)
+					}
+
+					msg, specModule := parseComment(spec.Doc)
+					if msg == "" {
+						msg, _ = parseComment(spec.Comment)
+					}
+					if msg == "" && len(decl.Specs) == 1 {
+						msg = groupMsg
+					}
+					if msg == "" {
+						msg = ident.Name
+					}
+					if specModule == "" {
+						specModule = groupModule
+					}
+					defs = append(defs, ErrorDef{
+						Code:   int(code),
+						Name:   ident.Name,
+						Msg:    msg,
+						Module: specModule,
+						Pos:    pos.Filename + ":" + strconv.Itoa(pos.Line),
+						typed:  obj.Type() != types.Typ[types.UntypedInt] && obj.Type() != types.Typ[types.Int],
+					})
+				}
+			}
+		}
+	}
+	return defs, files[0].Name.Name, nil
+}
+
+// 解析注释，返回错误信息和 @module 指定的模块
+func parseComment(g *ast.CommentGroup) (string, string) {
+	if g == nil {
+		return "", ""
+	}
+	lines := make([]string, 0)
+	module := ""
+	for _, line := range strings.Split(g.Text(), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, moduleTag) {
+			module = strings.TrimSpace(strings.TrimPrefix(line, moduleTag))
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, " "), module
+}
+
+// 是否为生成的文件
+func isGenerated(f *ast.File) bool {
+	for _, g := range f.Comments {
+		if g.Pos() > f.Package {
+			break
+		}
+		for _, c := range g.List {
+			if generatedRe.MatchString(c.Text) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// 检查错误码是否重复以及是否在模块的范围内，返回所有的问题
+func CheckErrorDefs(defs []ErrorDef, ranges map[string]CodeRange) error {
+	problems := make([]string, 0)
+	codes := make(map[int]ErrorDef)
+	names := make(map[string]ErrorDef)
+	for _, d := range defs {
+		if prev, ok := codes[d.Code]; ok {
+			problems = append(problems, fmt.Sprintf("%s: duplicate code %d of %s, already used by %s at %s", d.Pos, d.Code, d.Name, prev.Name, prev.Pos))
+		} else {
+			codes[d.Code] = d
+		}
+		if prev, ok := names[d.Name]; ok {
+			problems = append(problems, fmt.Sprintf("%s: duplicate name %s, already defined at %s", d.Pos, d.Name, prev.Pos))
+		} else {
+			names[d.Name] = d
+		}
+		if r, ok := ranges[d.Module]; ok {
+			if d.Code < r.Min || d.Code > r.Max {
+				problems = append(problems, fmt.Sprintf("%s: code %d of %s out of module %s range [%d, %d]", d.Pos, d.Code, d.Name, d.Module, r.Min, r.Max))
+			}
+			continue
+		}
+		for module, r := range ranges {
+			if d.Code >= r.Min && d.Code <= r.Max {
+				problems = append(problems, fmt.Sprintf("%s: code %d of %s belongs to module %s", d.Pos, d.Code, d.Name, module))
+				break
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid error codes:\n%s", strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+// 生成的Go文件是否与错误定义在同一个目录，同一目录时不再生成常量
+func sameDir(file string, sources []string) bool {
+	dir, err := filepath.Abs(filepath.Dir(file))
+	if err != nil {
+		return false
+	}
+	for _, src := range sources {
+		abs, err := filepath.Abs(src)
+		if err != nil {
+			continue
+		}
+		if info, err := os.Stat(abs); err == nil && !info.IsDir() {
+			abs = filepath.Dir(abs)
+		}
+		if abs == dir {
+			return true
+		}
+	}
+	return false
+}
+
+// 生成Go文件，包含错误码常量和错误信息
+// @param bool refOnly 错误码常量已经存在，只生成错误信息
+func genGoFile(defs []ErrorDef, conf GenConfig, refOnly bool) ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteString(genHeader + "\n\n")
+	b.WriteString("package " + conf.Package + "\n\n")
+	if conf.Register && conf.Package != "errors" {
+		b.WriteString("import \"github.com/Mueat/frm-lib/errors\"\n\n")
+	}
+	if !refOnly && len(defs) > 0 {
+		b.WriteString("const (\n")
+		for _, d := range defs {
+			b.WriteString("\t// " + d.Msg + "\n")
+			b.WriteString(fmt.Sprintf("\t%s = %d\n", d.Name, d.Code))
+		}
+		b.WriteString(")\n\n")
+	}
+	b.WriteString("var " + conf.VarName + " = map[int]string{\n")
+	for _, d := range defs {
+		key := d.Name
+		if refOnly && d.typed {
+			key = "int(" + d.Name + ")"
+		}
+		b.WriteString(fmt.Sprintf("\t%s: %s,\n", key, strconv.Quote(d.Msg)))
+	}
+	b.WriteString("}\n")
+	if conf.Register {
+		b.WriteString("\nfunc init() {\n")
+		if conf.Package == "errors" {
+			b.WriteString("\tAddErrors(" + conf.VarName + ")\n")
+		} else {
+			b.WriteString("\terrors.AddErrors(" + conf.VarName + ")\n")
+		}
+		b.WriteString("}\n")
+	}
+	return format.Source(b.Bytes())
+}
+
+// 将错误码写入到JSON文件
+func WriteErrorsJSON(defs []ErrorDef, file string) error {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(defs); err != nil {
+		return err
+	}
+	return writeStrToFile(b.String(), file)
+}
+
+// 将错误码写入到TypeScript文件，包含 ErrorCode 枚举和 ErrorMessages
+func WriteErrorsTS(defs []ErrorDef, file string) error {
+	var b strings.Builder
+	b.WriteString(genHeader + "\n\n")
+	b.WriteString("export enum ErrorCode {\n")
+	for _, d := range defs {
+		b.WriteString(fmt.Sprintf("  %s = %d,\n", d.Name, d.Code))
+	}
+	b.WriteString("}\n\n")
+	b.WriteString("export const ErrorMessages: Record<number, string> = {\n")
+	for _, d := range defs {
+		msg, _ := json.Marshal(d.Msg)
+		b.WriteString(fmt.Sprintf("  [ErrorCode.%s]: %s,\n", d.Name, msg))
+	}
+	b.WriteString("};\n")
+	return writeStrToFile(b.String(), file)
+}
+
+// 将错误码写入到Markdown文件，有多个模块时按模块分组
+func WriteErrorsMarkdown(defs []ErrorDef, file string) error {
+	modules := make([]string, 0)
+	groups := make(map[string][]ErrorDef)
+	withName := false
+	for _, d := range defs {
+		if _, ok := groups[d.Module]; !ok {
+			modules = append(modules, d.Module)
+		}
+		groups[d.Module] = append(groups[d.Module], d)
+		if d.Name != "" {
+			withName = true
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("## Error Code\n")
+	for _, m := range modules {
+		if len(modules) > 1 {
+			b.WriteString("\n### " + m + "\n\n")
+		}
+		if withName {
+			b.WriteString("| code | name | msg |\n")
+			b.WriteString("| -- | -- | -- |\n")
+		} else {
+			b.WriteString("| code | msg |\n")
+			b.WriteString("| -- | -- |\n")
+		}
+		for _, d := range groups[m] {
+			msg := strings.ReplaceAll(d.Msg, "|", "\\|")
+			if withName {
+				b.WriteString(fmt.Sprintf("| %d | %s | %s |\n", d.Code, d.Name, msg))
+			} else {
+				b.WriteString(fmt.Sprintf("| %d | %s |\n", d.Code, msg))
+			}
+		}
+	}
+	return writeStrToFile(b.String(), file)
+}
@@ -0,0 +1,270 @@
+package errors
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, dir, name, src string) string {
+	t.Helper()
+	file := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(file, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func assertDefs(t *testing.T, defs []ErrorDef, expected []ErrorDef) {
+	t.Helper()
+	if len(defs) != len(expected) {
+		t.Fatalf("expected %d defs, got %+v", len(expected), defs)
+	}
+	for i, e := range expected {
+		d := defs[i]
+		if d.Code != e.Code || d.Name != e.Name || d.Msg != e.Msg || d.Module != e.Module {
+			t.Errorf("def %d: expected %+v, got %+v", i, e, d)
+		}
+	}
+}
+
+func TestParseErrorDefsIota(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "user.go", `package errs
+
+// @module account
+const (
+	// 用户不存在
+	UserNotFound = iota + 10000
+	PasswordWrong // 密码错误
+	_
+	UserLocked
+)
+
+// 单独的常量
+const TokenExpired = 20000
+
+const (
+	base    = 30000
+	Unknown = base + 1 // 未知错误
+	name    = "user"
+)
+`)
+	defs, pkg, err := ParseErrorDefs([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pkg != "errs" {
+		t.Fatalf("unexpected package: %s", pkg)
+	}
+	assertDefs(t, defs, []ErrorDef{
+		{Code: 10000, Name: "UserNotFound", Msg: "用户不存在", Module: "account"},
+		{Code: 10001, Name: "PasswordWrong", Msg: "密码错误", Module: "account"},
+		{Code: 10003, Name: "UserLocked", Msg: "UserLocked", Module: "account"},
+		{Code: 20000, Name: "TokenExpired", Msg: "单独的常量", Module: "user"},
+		{Code: 30001, Name: "Unknown", Msg: "未知错误", Module: "user"},
+	})
+}
+
+func TestGenerateTyped(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "codes.go", `package errs
+
+type ErrCode int
+
+const (
+	NotFound ErrCode = iota + 404 // 不存在
+	Conflict ErrCode = 409       // 冲突
+	Internal = 500               // 内部错误
+)
+`)
+	goFile := filepath.Join(dir, "codes_gen.go")
+	defs, err := Generate(GenConfig{Sources: []string{dir}, GoFile: goFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertDefs(t, defs, []ErrorDef{
+		{Code: 404, Name: "NotFound", Msg: "不存在", Module: "codes"},
+		{Code: 409, Name: "Conflict", Msg: "冲突", Module: "codes"},
+		{Code: 500, Name: "Internal", Msg: "内部错误", Module: "codes"},
+	})
+	data, err := ioutil.ReadFile(goFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(data)
+	// 同一目录下只生成错误信息，类型常量需要转换为int
+	for _, s := range []string{genHeader, "int(NotFound):", "int(Conflict):", "\tInternal:"} {
+		if !strings.Contains(src, s) {
+			t.Errorf("generated file should contain %q:\n%s", s, src)
+		}
+	}
+	if strings.Contains(src, "const (") {
+		t.Errorf("generated file should not redefine constants:\n%s", src)
+	}
+
+	// 再次生成时跳过生成的文件
+	if _, err := Generate(GenConfig{Sources: []string{dir}, GoFile: goFile}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGenerateOutputDir(t *testing.T) {
+	dir := t.TempDir()
+	src := writeTestFile(t, dir, "codes.go", `package errs
+
+const (
+	NotFound = 404 // 不存在
+)
+`)
+	out := filepath.Join(dir, "gen", "docs")
+	_, err := Generate(GenConfig{
+		Sources:  []string{src},
+		GoFile:   filepath.Join(out, "errors.go"),
+		Package:  "gen",
+		JSONFile: filepath.Join(out, "errors.json"),
+		TSFile:   filepath.Join(out, "errors.ts"),
+		MDFile:   filepath.Join(out, "errors.md"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"errors.go", "errors.json", "errors.ts", "errors.md"} {
+		if _, err := os.Stat(filepath.Join(out, name)); err != nil {
+			t.Errorf("%s should be generated: %v", name, err)
+		}
+	}
+
+	// 目录无法创建时返回错误
+	_, err = Generate(GenConfig{Sources: []string{src}, JSONFile: filepath.Join(src, "sub", "errors.json")})
+	if err == nil {
+		t.Fatal("expected an error when the output directory cannot be created")
+	}
+}
+
+func TestCheckErrorDefs(t *testing.T) {
+	ranges := map[string]CodeRange{
+		"user":  {Min: 10000, Max: 19999},
+		"order": {Min: 20000, Max: 29999},
+	}
+	cases := []struct {
+		name     string
+		defs     []ErrorDef
+		problems []string
+	}{
+		{
+			name: "valid",
+			defs: []ErrorDef{
+				{Code: 10000, Name: "UserNotFound", Module: "user", Pos: "user.go:3"},
+				{Code: 20000, Name: "OrderNotFound", Module: "order", Pos: "order.go:3"},
+				{Code: 500, Name: "Internal", Module: "common", Pos: "common.go:3"},
+			},
+		},
+		{
+			name: "duplicate code",
+			defs: []ErrorDef{
+				{Code: 10000, Name: "UserNotFound", Module: "user", Pos: "user.go:3"},
+				{Code: 10000, Name: "UserLocked", Module: "user", Pos: "user.go:4"},
+			},
+			problems: []string{"user.go:4: duplicate code 10000 of UserLocked, already used by UserNotFound at user.go:3"},
+		},
+		{
+			name: "duplicate name",
+			defs: []ErrorDef{
+				{Code: 10000, Name: "NotFound", Module: "user", Pos: "user.go:3"},
+				{Code: 20000, Name: "NotFound", Module: "order", Pos: "order.go:3"},
+			},
+			problems: []string{"order.go:3: duplicate name NotFound, already defined at user.go:3"},
+		},
+		{
+			name: "out of range",
+			defs: []ErrorDef{
+				{Code: 20001, Name: "UserLocked", Module: "user", Pos: "user.go:3"},
+			},
+			problems: []string{"user.go:3: code 20001 of UserLocked out of module user range [10000, 19999]"},
+		},
+		{
+			name: "other module range",
+			defs: []ErrorDef{
+				{Code: 10001, Name: "PayFailed", Module: "pay", Pos: "pay.go:3"},
+			},
+			problems: []string{"pay.go:3: code 10001 of PayFailed belongs to module user"},
+		},
+	}
+	for _, c := range cases {
+		err := CheckErrorDefs(c.defs, ranges)
+		if len(c.problems) == 0 {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", c.name, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("%s: expected an error", c.name)
+			continue
+		}
+		for _, p := range c.problems {
+			if !strings.Contains(err.Error(), p) {
+				t.Errorf("%s: error should contain %q, got %v", c.name, p, err)
+			}
+		}
+	}
+}
+
+func TestGenerateRangeViolation(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "user.go", `package errs
+
+const (
+	UserNotFound = iota + 10000 // 用户不存在
+	UserLocked                  // 用户被锁定
+)
+`)
+	writeTestFile(t, dir, "order.go", `package errs
+
+const OrderNotFound = 10000 // 订单不存在
+`)
+	goFile := filepath.Join(dir, "errors_gen.go")
+	_, err := Generate(GenConfig{
+		Sources: []string{dir},
+		GoFile:  goFile,
+		Ranges:  map[string]CodeRange{"user": {Min: 10000, Max: 10000}},
+	})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, p := range []string{"duplicate code 10000", "code 10001 of UserLocked out of module user range", "code 10000 of OrderNotFound belongs to module user"} {
+		if !strings.Contains(err.Error(), p) {
+			t.Errorf("error should contain %q, got %v", p, err)
+		}
+	}
+	if _, err := os.Stat(goFile); !os.IsNotExist(err) {
+		t.Fatal("nothing should be generated for invalid codes")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	dir := t.TempDir()
+	src := writeTestFile(t, dir, "codes.go", `package errs
+
+const (
+	NotFound = 404 // 不存在
+)
+`)
+	errorMap, err := ParseErrors(src, filepath.Join(dir, "gen", "errors.go"), "gen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errorMap) != 1 || errorMap[404] != "不存在" {
+		t.Fatalf("unexpected errors: %v", errorMap)
+	}
+	if _, err := ParseErrors(filepath.Join(dir, "missing.go"), "", ""); err == nil {
+		t.Fatal("expected an error for a missing file")
+	}
+	writeTestFile(t, dir, "broken.go", "package errs\n\nconst (\n")
+	if _, err := ParseErrors(filepath.Join(dir, "broken.go"), "", ""); err == nil {
+		t.Fatal("expected an error for an invalid file")
+	}
+}
@@ -0,0 +1,152 @@
+package errors
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+)
+
+// 多语言错误信息，key为小写的语言标签，如 zh-cn、en
+var (
+	catalogs   = make(map[string]map[int]string)
+	catalogsMu sync.RWMutex
+)
+
+// 统一语言标签的格式，zh_CN => zh-cn
+func normalizeLocale(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+}
+
+// 添加指定语言的错误信息
+// 可以与 Generate 生成的错误信息配合使用：errors.AddLocaleErrors("zh-CN", errs.Errors)
+func AddLocaleErrors(locale string, errMap map[int]string) {
+	catalogsMu.Lock()
+	defer catalogsMu.Unlock()
+	locale = normalizeLocale(locale)
+	catalog, ok := catalogs[locale]
+	if !ok {
+		catalog = make(map[int]string)
+		catalogs[locale] = catalog
+	}
+	for k, v := range errMap {
+		catalog[k] = v
+	}
+}
+
+// 从文件加载指定语言的错误信息，支持json和toml，key为错误码
+//
+//	# zh-CN.toml
+//	401 = "请先登录"
+//	2 = "{field}长度不能小于{min}"
+func LoadLocaleFile(locale string, file string) error {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	raw := make(map[string]string)
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".toml":
+		err = toml.Unmarshal(content, &raw)
+	default:
+		err = json.Unmarshal(content, &raw)
+	}
+	if err != nil {
+		return err
+	}
+	errMap := make(map[int]string, len(raw))
+	for k, v := range raw {
+		code, err := strconv.Atoi(k)
+		if err != nil {
+			return fmt.Errorf("invalid error code %q in %s", k, file)
+		}
+		errMap[code] = v
+	}
+	AddLocaleErrors(locale, errMap)
+	return nil
+}
+
+// 加载目录中的所有语言文件，文件名为语言标签，如 zh-CN.toml、en.json
+func LoadLocaleDir(dir string) error {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		ext := strings.ToLower(filepath.Ext(f.Name()))
+		if f.IsDir() || (ext != ".json" && ext != ".toml") {
+			continue
+		}
+		locale := strings.TrimSuffix(f.Name(), filepath.Ext(f.Name()))
+		if err := LoadLocaleFile(locale, filepath.Join(dir, f.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 匹配已加载的语言，优先完全匹配，其次匹配主语言，如 zh-TW 匹配 zh，zh 匹配 zh-cn
+// 没有匹配的语言时返回空字符串
+func MatchLocale(locale string) string {
+	locale = normalizeLocale(locale)
+	if locale == "" {
+		return ""
+	}
+	catalogsMu.RLock()
+	defer catalogsMu.RUnlock()
+	if _, ok := catalogs[locale]; ok {
+		return locale
+	}
+	base := strings.SplitN(locale, "-", 2)[0]
+	if _, ok := catalogs[base]; ok {
+		return base
+	}
+	keys := make([]string, 0, len(catalogs))
+	for k := range catalogs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if strings.SplitN(k, "-", 2)[0] == base {
+			return k
+		}
+	}
+	return ""
+}
+
+// 获取指定语言的错误信息，不存在时使用 Errors 中的信息
+func GetLocaleMsg(locale string, code int) string {
+	if msg, ok := LookupLocaleMsg(locale, code); ok {
+		return msg
+	}
+	return GetErrorMsg(code)
+}
+
+// 获取指定语言的错误信息，语言包中不存在时返回false
+func LookupLocaleMsg(locale string, code int) (string, bool) {
+	if locale = MatchLocale(locale); locale == "" {
+		return "", false
+	}
+	catalogsMu.RLock()
+	defer catalogsMu.RUnlock()
+	msg, ok := catalogs[locale][code]
+	return msg, ok
+}
+
+// 替换信息中的占位符，如 "{field}长度不能小于{min}"，不存在的占位符保持不变
+func Format(msg string, params map[string]interface{}) string {
+	if len(params) == 0 || !strings.Contains(msg, "{") {
+		return msg
+	}
+	pairs := make([]string, 0, len(params)*2)
+	for k, v := range params {
+		pairs = append(pairs, "{"+k+"}", fmt.Sprint(v))
+	}
+	return strings.NewReplacer(pairs...).Replace(msg)
+}
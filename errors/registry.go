@@ -0,0 +1,97 @@
+package errors
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"reflect"
+	"sync"
+)
+
+// 错误匹配方法，匹配成功时返回对应的错误码
+type Matcher func(err error) (int, bool)
+
+var (
+	matchers   = make([]Matcher, 0)
+	matchersMu sync.RWMutex
+)
+
+func init() {
+	Register(context.DeadlineExceeded, GatewayTimeout)
+	RegisterType((*json.SyntaxError)(nil), Params)
+	RegisterType((*json.UnmarshalTypeError)(nil), Params)
+}
+
+// 注册错误匹配方法，后注册的优先匹配，应用可以覆盖内置的对应关系
+func RegisterMatcher(m Matcher) {
+	matchersMu.Lock()
+	defer matchersMu.Unlock()
+	matchers = append(matchers, m)
+}
+
+// 注册错误值对应的错误码，使用 errors.Is 匹配
+//
+//	errors.Register(gorm.ErrRecordNotFound, errors.ModelNotFound)
+func Register(target error, code int) {
+	RegisterMatcher(func(err error) (int, bool) {
+		return code, stderrors.Is(err, target)
+	})
+}
+
+// 注册错误类型对应的错误码，错误链中存在该类型的错误时匹配
+//
+//	errors.RegisterType((*json.SyntaxError)(nil), errors.Params)
+func RegisterType(target error, code int) {
+	t := reflect.TypeOf(target)
+	RegisterMatcher(func(err error) (int, bool) {
+		for err != nil {
+			if reflect.TypeOf(err) == t {
+				return code, true
+			}
+			err = stderrors.Unwrap(err)
+		}
+		return 0, false
+	})
+}
+
+// 查找错误对应的错误码
+func Lookup(err error) (int, bool) {
+	if err == nil {
+		return 0, false
+	}
+	matchersMu.RLock()
+	defer matchersMu.RUnlock()
+	for i := len(matchers) - 1; i >= 0; i-- {
+		if code, ok := matchers[i](err); ok {
+			return code, true
+		}
+	}
+	return 0, false
+}
+
+// 将任意错误转换为 *Err
+// 错误链中已经有 *Err 时直接返回，否则根据注册的对应关系设置错误码，未匹配时使用 System
+func From(err error) *Err {
+	return FromSkip(err, 1)
+}
+
+// 与 From 相同，可以指定记录的调用位置
+// @param int skip 跳过的调用层数，0表示调用FromSkip的函数
+func FromSkip(err error, skip int) *Err {
+	if err == nil {
+		return nil
+	}
+	var e *Err
+	if stderrors.As(err, &e) && e != nil {
+		return e
+	}
+	code, ok := Lookup(err)
+	if !ok {
+		code = System
+	}
+	e = newErr(skip + 1)
+	e.Code = code
+	e.Msg = GetErrorMsg(code)
+	e.cause = err
+	return e
+}
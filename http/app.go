@@ -1,6 +1,7 @@
 package http
 
 import (
+	"context"
 	"mime/multipart"
 	"strconv"
 
@@ -8,6 +9,7 @@ import (
 	"github.com/Mueat/frm-lib/db"
 	"github.com/Mueat/frm-lib/errors"
 	"github.com/Mueat/frm-lib/log"
+	"github.com/Mueat/frm-lib/trace"
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog"
 	"gorm.io/gorm"
@@ -32,6 +34,17 @@ func (a *App) GetContext() *gin.Context {
 	return a.Request.Ctx
 }
 
+// 获取请求的context
+// 客户端断开连接、请求超时后会被取消，并携带请求ID，调用db、redis、curl时传入以便取消和追踪
+func (a *App) Context() context.Context {
+	return a.Request.Ctx.Request.Context()
+}
+
+// 获取请求ID
+func (a *App) RequestID() string {
+	return trace.FromContext(a.Context())
+}
+
 // 获取body数据
 func (a *App) GetBody() []byte {
 	return a.Request.GetBody()
@@ -184,6 +197,11 @@ func (a *App) GetFloat64(key string) float64 {
 	return a.Request.GetFloat64(key)
 }
 
+// 获取当前路由声明的权限
+func (a *App) RoutePerm() string {
+	return GetRoutePerm(a.Request.Ctx)
+}
+
 // Abort
 func (a *App) Abort() {
 	a.Response.Abort()
@@ -214,65 +232,89 @@ func (a *App) HTML(name string, obj interface{}) {
 	a.Response.HTML(200, name, obj)
 }
 
-func (a *App) Resp(v interface{}, err *errors.Err) {
-	a.Response.Resp(v, err)
+// 返回数据或者错误，err 可以是 *errors.Err 或者普通错误
+//
+//	var order Order
+//	app.Resp(order, app.DefaultDB().First(&order, id).Error)
+func (a *App) Resp(v interface{}, err error) {
+	a.Response.resp(v, err)
 }
 
 func (a *App) Success(v interface{}) {
 	a.Response.Success(v)
 }
 
-func (a *App) Error(code int) {
+// 返回错误码对应的错误信息，启用多语言时返回请求语言的信息
+// params 为信息中占位符的参数，如 app.Error(errors.Params, map[string]interface{}{"field": "name"})
+func (a *App) Error(code int, params ...map[string]interface{}) {
 	msg, ok := errors.Errors[code]
 	if !ok {
 		msg = "Unkonw Error"
 	}
-	a.Response.Error(code, msg)
+	// 语言包中有该错误码时使用语言包中的信息
+	if i18nConf != nil {
+		if _, ok := errors.LookupLocaleMsg(a.Locale(), code); ok {
+			msg = ""
+		}
+	}
+	var p map[string]interface{}
+	if len(params) > 0 {
+		p = params[0]
+	}
+	a.Response.Error(code, a.Response.localize(code, msg, p))
 }
 
 func (a *App) ErrorMsg(msg string) {
 	a.Response.Error(-1, msg)
 }
 
-// 数据库
+// 数据库，使用请求的context，请求超时或者取消后查询也会被取消
 func (a *App) DB(name string) *gorm.DB {
-	return db.GetMySql(name)
+	conn := db.GetMySql(name)
+	if conn == nil {
+		return nil
+	}
+	return conn.WithContext(a.Context())
 }
 
 func (a *App) DefaultDB() *gorm.DB {
-	return db.GetMySql("")
+	return a.DB("")
 }
 
-// redis
+// redis，使用请求的context，请求超时或者取消后操作也会被取消
 func (a *App) Redis(name string) *cache.Pools {
-	return cache.GetRedis(name)
+	pool := cache.GetRedis(name)
+	if pool == nil {
+		return nil
+	}
+	return pool.WithContext(a.Context())
 }
 
 func (a *App) DefaultRedis() *cache.Pools {
-	return cache.GetRedis("")
+	return a.Redis("")
 }
 
-// 日志
+// 日志，会记录请求ID，请求通过签名请求头开启debug日志时输出debug日志
 func (a *App) Log(name string) *zerolog.Logger {
-	return log.Get(name)
+	return log.Ctx(a.Context(), name)
 }
 
 func (a *App) LogDebug() *zerolog.Event {
-	return log.Debug()
+	return a.Log("").Debug()
 }
 
 func (a *App) LogInfo() *zerolog.Event {
-	return log.Info()
+	return a.Log("").Info()
 }
 
 func (a *App) LogError() *zerolog.Event {
-	return log.Error()
+	return a.Log("").Error()
 }
 
 func (a *App) LogFatal() *zerolog.Event {
-	return log.Fatal()
+	return a.Log("").Fatal()
 }
 
 func (a *App) LogPanic() *zerolog.Event {
-	return log.Panic()
+	return a.Log("").Panic()
 }
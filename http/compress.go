@@ -0,0 +1,263 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 压缩器构造方法
+type CompressorFunc func(w io.Writer, level int) (io.WriteCloser, error)
+
+// 已注册的压缩器，key为 Accept-Encoding 中的编码名称
+var (
+	compressors     = map[string]CompressorFunc{}
+	compressorOrder = make([]string, 0)
+	compressorsMu   sync.RWMutex
+)
+
+func init() {
+	RegisterCompressor("gzip", func(w io.Writer, level int) (io.WriteCloser, error) {
+		return gzip.NewWriterLevel(w, level)
+	})
+	RegisterCompressor("deflate", func(w io.Writer, level int) (io.WriteCloser, error) {
+		return flate.NewWriter(w, level)
+	})
+}
+
+// 注册压缩器，后注册的压缩器优先使用
+// 如注册brotli：RegisterCompressor("br", func(w io.Writer, level int) (io.WriteCloser, error) { return brotli.NewWriterLevel(w, level), nil })
+func RegisterCompressor(encoding string, fn CompressorFunc) {
+	compressorsMu.Lock()
+	defer compressorsMu.Unlock()
+	encoding = strings.ToLower(encoding)
+	if _, ok := compressors[encoding]; !ok {
+		compressorOrder = append([]string{encoding}, compressorOrder...)
+	}
+	compressors[encoding] = fn
+}
+
+// 压缩配置
+type CompressionConfig struct {
+	Level        int      // 压缩等级，默认：-1，使用压缩器的默认等级
+	MinSize      int      // 最小压缩大小，单位：字节，默认：1024
+	ContentTypes []string // 需要压缩的Content-Type前缀，默认：json、xml、javascript、css、html、plain、svg
+}
+
+var defaultCompressTypes = []string{
+	"application/json",
+	"application/problem+json",
+	"application/xml",
+	"application/javascript",
+	"text/html",
+	"text/css",
+	"text/plain",
+	"text/xml",
+	"text/javascript",
+	"image/svg+xml",
+}
+
+// 启用响应压缩
+// 根据 Accept-Encoding 选择压缩器，小于 MinSize 或者 Content-Type 不匹配的响应不压缩
+func (s *GinServer) SetCompression(conf CompressionConfig) {
+	if conf.Level == 0 {
+		conf.Level = -1
+	}
+	if conf.MinSize <= 0 {
+		conf.MinSize = 1024
+	}
+	if len(conf.ContentTypes) == 0 {
+		conf.ContentTypes = defaultCompressTypes
+	}
+
+	s.Engine.Use(func(c *gin.Context) {
+		encoding := negotiateEncoding(c.GetHeader("Accept-Encoding"))
+		c.Writer.Header().Add("Vary", "Accept-Encoding")
+		if encoding == "" || c.Request.Method == http.MethodHead {
+			return
+		}
+		w := &compressWriter{ResponseWriter: c.Writer, conf: &conf, encoding: encoding}
+		c.Writer = w
+		defer func() {
+			w.Close()
+			c.Writer = w.ResponseWriter
+		}()
+		c.Next()
+	})
+}
+
+// 根据 Accept-Encoding 选择压缩器
+func negotiateEncoding(accept string) string {
+	if accept == "" {
+		return ""
+	}
+	type candidate struct {
+		name string
+		q    float64
+	}
+	accepted := make(map[string]float64)
+	for _, part := range strings.Split(accept, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		q := 1.0
+		for _, f := range fields[1:] {
+			f = strings.TrimSpace(f)
+			if strings.HasPrefix(f, "q=") {
+				if v, err := strconv.ParseFloat(f[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		accepted[name] = q
+	}
+
+	compressorsMu.RLock()
+	defer compressorsMu.RUnlock()
+	candidates := make([]candidate, 0)
+	for _, name := range compressorOrder {
+		q, ok := accepted[name]
+		if !ok {
+			q, ok = accepted["*"]
+		}
+		if ok && q > 0 {
+			candidates = append(candidates, candidate{name: name, q: q})
+		}
+	}
+	if len(candidates) == 0 {
+		return ""
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].q > candidates[j].q
+	})
+	return candidates[0].name
+}
+
+// 压缩响应的ResponseWriter
+// 先缓存响应内容，达到最小压缩大小后再决定是否压缩
+type compressWriter struct {
+	gin.ResponseWriter
+	conf       *CompressionConfig
+	encoding   string
+	buf        bytes.Buffer
+	decided    bool
+	compressor io.WriteCloser
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if w.decided {
+		if w.compressor != nil {
+			return w.compressor.Write(b)
+		}
+		return w.ResponseWriter.Write(b)
+	}
+	w.buf.Write(b)
+	if w.buf.Len() >= w.conf.MinSize {
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// 流式响应需要立即输出已经缓存的内容
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		_ = w.decide(w.buf.Len() >= w.conf.MinSize)
+	}
+	if f, ok := w.compressor.(interface{ Flush() error }); ok {
+		_ = f.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if !w.decided {
+		w.decided = true
+	}
+	return w.ResponseWriter.Hijack()
+}
+
+func (w *compressWriter) Written() bool {
+	return w.decided || w.buf.Len() > 0 || w.ResponseWriter.Written()
+}
+
+func (w *compressWriter) Size() int {
+	if !w.decided {
+		return w.buf.Len()
+	}
+	return w.ResponseWriter.Size()
+}
+
+// 决定是否压缩，并输出缓存的内容
+func (w *compressWriter) decide(large bool) error {
+	w.decided = true
+	if large && w.shouldCompress() {
+		compressorsMu.RLock()
+		fn := compressors[w.encoding]
+		compressorsMu.RUnlock()
+		compressor, err := fn(w.ResponseWriter, w.conf.Level)
+		if err == nil {
+			h := w.ResponseWriter.Header()
+			h.Set("Content-Encoding", w.encoding)
+			h.Del("Content-Length")
+			// 压缩后内容变化，强ETag改为弱ETag
+			if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+				h.Set("ETag", "W/"+etag)
+			}
+			w.compressor = compressor
+		}
+	}
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	var err error
+	if w.compressor != nil {
+		_, err = w.compressor.Write(w.buf.Bytes())
+	} else {
+		_, err = w.ResponseWriter.Write(w.buf.Bytes())
+	}
+	w.buf.Reset()
+	return err
+}
+
+func (w *compressWriter) shouldCompress() bool {
+	h := w.ResponseWriter.Header()
+	if h.Get("Content-Encoding") != "" {
+		return false
+	}
+	status := w.ResponseWriter.Status()
+	if status < http.StatusOK || status == http.StatusNoContent || status == http.StatusNotModified || status == http.StatusPartialContent {
+		return false
+	}
+	ct := strings.ToLower(h.Get("Content-Type"))
+	for _, t := range w.conf.ContentTypes {
+		if strings.HasPrefix(ct, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// 请求结束，输出剩余的内容
+func (w *compressWriter) Close() {
+	if !w.decided {
+		_ = w.decide(false)
+	}
+	if w.compressor != nil {
+		_ = w.compressor.Close()
+	}
+}
@@ -0,0 +1,89 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Mueat/frm-lib/util"
+)
+
+// 根据响应内容生成ETag
+func makeETag(body []byte) string {
+	return "\"" + util.Md5(string(body)) + "\""
+}
+
+// 判断 If-None-Match 是否匹配ETag，使用弱比较
+func etagMatch(ifNoneMatch string, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, v := range strings.Split(ifNoneMatch, ",") {
+		v = strings.TrimSpace(v)
+		if v == "*" || strings.TrimPrefix(v, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// 是否是可以返回304的请求
+func isConditionalMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead
+}
+
+// 设置ETag，如果请求的 If-None-Match 匹配则返回304
+// 返回true表示已经返回304，不需要再输出内容
+func (r *Response) ETag(etag string) bool {
+	r.Ctx.Header("ETag", etag)
+	if isConditionalMethod(r.Ctx.Request.Method) && etagMatch(r.Ctx.GetHeader("If-None-Match"), etag) {
+		r.notModified()
+		return true
+	}
+	return false
+}
+
+// 设置最后修改时间，如果请求的 If-Modified-Since 不早于该时间则返回304
+// 返回true表示已经返回304，不需要再输出内容
+func (r *Response) LastModified(t time.Time) bool {
+	if t.IsZero() {
+		return false
+	}
+	t = t.UTC().Truncate(time.Second)
+	r.Ctx.Header("Last-Modified", t.Format(http.TimeFormat))
+	// 同时存在时以 If-None-Match 为准
+	if !isConditionalMethod(r.Ctx.Request.Method) || r.Ctx.GetHeader("If-None-Match") != "" {
+		return false
+	}
+	since, err := http.ParseTime(r.Ctx.GetHeader("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	if !t.After(since) {
+		r.notModified()
+		return true
+	}
+	return false
+}
+
+func (r *Response) notModified() {
+	h := r.Ctx.Writer.Header()
+	h.Del("Content-Type")
+	h.Del("Content-Length")
+	r.Ctx.AbortWithStatus(http.StatusNotModified)
+}
+
+// 设置ETag，返回true表示已经返回304
+func (a *App) ETag(etag string) bool {
+	return a.Response.ETag(etag)
+}
+
+// 设置最后修改时间，返回true表示已经返回304
+//
+//	if app.LastModified(order.UpdatedAt) {
+//		return
+//	}
+func (a *App) LastModified(t time.Time) bool {
+	return a.Response.LastModified(t)
+}
@@ -0,0 +1,168 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+var defaultCORSMethods = []string{
+	http.MethodGet,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+	http.MethodHead,
+	http.MethodOptions,
+}
+
+// 跨域配置，可以直接从toml文件中解析
+//
+//	[Server.CORS]
+//	AllowOrigins = ["https://www.example.com", "https://*.example.com"]
+//	AllowOriginRegexps = ["^https://[a-z]+-test\\.example\\.com$"]
+//	AllowCredentials = true
+//	MaxAge = 600
+type CORSConfig struct {
+	AllowOrigins       []string // 允许的来源，支持 * 和子域名通配符，如 https://*.example.com
+	AllowOriginRegexps []string // 使用正则匹配的来源
+	AllowMethods       []string // 允许的请求方法，默认：GET POST PUT PATCH DELETE HEAD OPTIONS
+	AllowHeaders       []string // 允许的请求头，为空时允许预检请求中声明的全部请求头
+	ExposeHeaders      []string // 允许前端读取的响应头
+	AllowCredentials   bool     // 是否允许携带cookie
+	MaxAge             int64    // 预检请求缓存时间，单位：秒
+}
+
+// 编译后的跨域配置
+type cors struct {
+	allowAll         bool
+	origins          map[string]bool
+	wildcards        [][2]string
+	regexps          []*regexp.Regexp
+	allowMethods     string
+	allowHeaders     string
+	exposeHeaders    string
+	allowCredentials bool
+	maxAge           string
+}
+
+// 启用跨域
+// 预检请求会在中间件中直接返回，不会进入路由和404处理
+// 正则错误或者 AllowOrigins 为 * 同时 AllowCredentials 为true时返回错误，不启用跨域
+func (s *GinServer) SetCORS(conf CORSConfig) error {
+	cs, err := newCORS(conf)
+	if err != nil {
+		return err
+	}
+	s.Engine.Use(cs.handle)
+	return nil
+}
+
+func newCORS(conf CORSConfig) (*cors, error) {
+	cs := &cors{
+		origins:          make(map[string]bool),
+		allowCredentials: conf.AllowCredentials,
+	}
+	for _, o := range conf.AllowOrigins {
+		o = strings.ToLower(strings.TrimSpace(o))
+		if o == "*" {
+			cs.allowAll = true
+		} else if pos := strings.Index(o, "*"); pos > -1 {
+			cs.wildcards = append(cs.wildcards, [2]string{o[:pos], o[pos+1:]})
+		} else if o != "" {
+			cs.origins[o] = true
+		}
+	}
+	// 允许任意来源携带cookie会使任意网站都可以以用户身份发起请求
+	if cs.allowAll && cs.allowCredentials {
+		return nil, fmt.Errorf("http: cors AllowOrigins * cannot be used with AllowCredentials")
+	}
+	for _, r := range conf.AllowOriginRegexps {
+		re, err := regexp.Compile(r)
+		if err != nil {
+			return nil, fmt.Errorf("http: invalid cors origin regexp %q: %v", r, err)
+		}
+		cs.regexps = append(cs.regexps, re)
+	}
+	methods := conf.AllowMethods
+	if len(methods) == 0 {
+		methods = defaultCORSMethods
+	}
+	cs.allowMethods = strings.ToUpper(strings.Join(methods, ", "))
+	cs.allowHeaders = strings.Join(conf.AllowHeaders, ", ")
+	cs.exposeHeaders = strings.Join(conf.ExposeHeaders, ", ")
+	if conf.MaxAge > 0 {
+		cs.maxAge = strconv.FormatInt(conf.MaxAge, 10)
+	}
+	return cs, nil
+}
+
+func (cs *cors) handle(c *gin.Context) {
+	origin := c.GetHeader("Origin")
+	if origin == "" {
+		return
+	}
+	h := c.Writer.Header()
+	h.Add("Vary", "Origin")
+	preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+	if !cs.allowOrigin(origin) {
+		if preflight {
+			c.AbortWithStatus(http.StatusForbidden)
+		}
+		return
+	}
+
+	if cs.allowAll && !cs.allowCredentials {
+		h.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		h.Set("Access-Control-Allow-Origin", origin)
+	}
+	if cs.allowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	if !preflight {
+		if cs.exposeHeaders != "" {
+			h.Set("Access-Control-Expose-Headers", cs.exposeHeaders)
+		}
+		return
+	}
+
+	h.Add("Vary", "Access-Control-Request-Method")
+	h.Add("Vary", "Access-Control-Request-Headers")
+	h.Set("Access-Control-Allow-Methods", cs.allowMethods)
+	if cs.allowHeaders != "" {
+		h.Set("Access-Control-Allow-Headers", cs.allowHeaders)
+	} else if reqHeaders := c.GetHeader("Access-Control-Request-Headers"); reqHeaders != "" {
+		h.Set("Access-Control-Allow-Headers", reqHeaders)
+	}
+	if cs.maxAge != "" {
+		h.Set("Access-Control-Max-Age", cs.maxAge)
+	}
+	c.AbortWithStatus(http.StatusNoContent)
+}
+
+func (cs *cors) allowOrigin(origin string) bool {
+	if cs.allowAll {
+		return true
+	}
+	o := strings.ToLower(origin)
+	if cs.origins[o] {
+		return true
+	}
+	for _, w := range cs.wildcards {
+		if len(o) > len(w[0])+len(w[1]) && strings.HasPrefix(o, w[0]) && strings.HasSuffix(o, w[1]) {
+			return true
+		}
+	}
+	for _, r := range cs.regexps {
+		if r.MatchString(origin) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,156 @@
+package http
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/Mueat/frm-lib/errors"
+	elog "github.com/Mueat/frm-lib/log"
+	"github.com/Mueat/frm-lib/util"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// gin.Context中保存csrf token的key
+	csrfCtxKey = "$csrf_token"
+	// 会话中保存csrf token的key
+	csrfSessionKey = "_csrf"
+
+	DefaultCSRFCookieName = "XSRF-TOKEN"
+	DefaultCSRFHeaderName = "X-CSRF-Token"
+	DefaultCSRFFormField  = "_csrf"
+)
+
+// csrf配置
+type CSRFConfig struct {
+	Secret       string   // token签名秘钥
+	UseSession   bool     // 是否将token保存在会话中，需要先调用 GinServer.SetSession，否则使用双重提交cookie
+	CookieName   string   // 双重提交cookie的名称，默认：XSRF-TOKEN
+	HeaderName   string   // 提交token的请求头，默认：X-CSRF-Token
+	FormField    string   // 提交token的表单字段，默认：_csrf
+	Path         string   // cookie路径，默认：/
+	Domain       string   // cookie域名
+	Secure       bool     // 是否只在https下发送cookie
+	SameSite     string   // Lax Strict None
+	ExcludePaths []string // 不校验的路径前缀，如第三方回调地址
+}
+
+// 启用csrf校验
+// POST PUT PATCH DELETE 请求需要在请求头或者表单中提交token，token可以通过 App.CSRFToken 获取
+// Secret 为空或者 UseSession 为true但是没有先调用 GinServer.SetSession 时panic
+func (s *GinServer) SetCSRF(conf CSRFConfig) {
+	if conf.Secret == "" {
+		panic("http: csrf requires a secret")
+	}
+	if conf.UseSession && !s.session {
+		panic("http: csrf with UseSession requires GinServer.SetSession to be called first")
+	}
+	if conf.CookieName == "" {
+		conf.CookieName = DefaultCSRFCookieName
+	}
+	if conf.HeaderName == "" {
+		conf.HeaderName = DefaultCSRFHeaderName
+	}
+	if conf.FormField == "" {
+		conf.FormField = DefaultCSRFFormField
+	}
+	if conf.Path == "" {
+		conf.Path = "/"
+	}
+
+	s.Engine.Use(func(c *gin.Context) {
+		app := InitApp(c)
+		token := conf.getToken(&app)
+		if token == "" {
+			var err error
+			if token, err = conf.newToken(&app); err != nil {
+				elog.Error().Err(err).Str("type", ErrPack).Str("name", "csrf").Str("method", "newToken").Send()
+				c.AbortWithStatus(http.StatusInternalServerError)
+				return
+			}
+		}
+		c.Set(csrfCtxKey, token)
+
+		if !isUnsafeMethod(c.Request.Method) {
+			return
+		}
+		for _, p := range conf.ExcludePaths {
+			if strings.HasPrefix(c.Request.URL.Path, p) {
+				return
+			}
+		}
+		submitted := c.GetHeader(conf.HeaderName)
+		if submitted == "" {
+			submitted = c.PostForm(conf.FormField)
+		}
+		if submitted == "" || subtle.ConstantTimeCompare([]byte(submitted), []byte(token)) != 1 {
+			app.Response.Error(errors.Forbidden, "CSRF token mismatch")
+			app.Abort()
+		}
+	})
+}
+
+// 获取csrf token，用于模板或者返回给前端
+func (a *App) CSRFToken() string {
+	return a.GetString(csrfCtxKey)
+}
+
+// 获取已有的token
+func (conf *CSRFConfig) getToken(app *App) string {
+	if conf.UseSession {
+		if sess := app.Session(); sess != nil {
+			return sess.GetString(csrfSessionKey)
+		}
+		return ""
+	}
+	cookie, err := app.Request.Ctx.Cookie(conf.CookieName)
+	if err != nil || !conf.validToken(cookie) {
+		return ""
+	}
+	return cookie
+}
+
+// 生成新的token并保存，随机数生成失败时返回错误，不使用可预测的token
+func (conf *CSRFConfig) newToken(app *App) (string, error) {
+	nonce, err := util.GenerateNonce(32)
+	if err != nil {
+		return "", err
+	}
+	token := nonce + "." + util.HMAC(sha256.New, nonce, conf.Secret)
+	if conf.UseSession {
+		if sess := app.Session(); sess != nil {
+			sess.Set(csrfSessionKey, token)
+		}
+		return token, nil
+	}
+	// 前端框架需要读取cookie放到请求头中，所以不能设置HttpOnly
+	http.SetCookie(app.Request.Ctx.Writer, &http.Cookie{
+		Name:     conf.CookieName,
+		Value:    token,
+		Path:     conf.Path,
+		Domain:   conf.Domain,
+		Secure:   conf.Secure,
+		SameSite: parseSameSite(conf.SameSite),
+	})
+	return token, nil
+}
+
+// 校验token签名，防止攻击者通过子域名写入任意cookie
+func (conf *CSRFConfig) validToken(token string) bool {
+	pos := strings.LastIndex(token, ".")
+	if pos < 1 {
+		return false
+	}
+	sign := util.HMAC(sha256.New, token[:pos], conf.Secret)
+	return subtle.ConstantTimeCompare([]byte(sign), []byte(token[pos+1:])) == 1
+}
+
+func isUnsafeMethod(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
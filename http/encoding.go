@@ -0,0 +1,258 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/render"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// 内置的响应编码
+const (
+	EncodingJSON     = "json"
+	EncodingXML      = "xml"
+	EncodingMsgPack  = "msgpack"
+	EncodingProtobuf = "protobuf"
+)
+
+// 响应编码器，返回gin的render
+type Encoder func(v interface{}) render.Render
+
+type encoderEntry struct {
+	name      string
+	mimeTypes []string
+	encoder   Encoder
+}
+
+// 已注册的编码器
+var (
+	encoders   = make(map[string]*encoderEntry)
+	encoderMu  sync.RWMutex
+	mimeLookup = make(map[string]string)
+)
+
+func init() {
+	registerEncoder(EncodingJSON, []string{"application/json", "text/json"}, nil)
+	registerEncoder(EncodingXML, []string{"application/xml", "text/xml"}, func(v interface{}) render.Render {
+		return xmlRender{Data: v}
+	})
+	registerEncoder(EncodingMsgPack, []string{"application/msgpack", "application/x-msgpack"}, func(v interface{}) render.Render {
+		return render.MsgPack{Data: v}
+	})
+	registerEncoder(EncodingProtobuf, []string{"application/x-protobuf", "application/protobuf"}, func(v interface{}) render.Render {
+		return protobufRender{Data: v}
+	})
+}
+
+// 注册响应编码器，已存在的编码器会被替换
+// @param string name 编码名称，用于路由指定编码
+// @param []string mimeTypes 对应的 Accept 类型
+// @param Encoder encoder 编码器
+func (s *GinServer) RegisterEncoder(name string, mimeTypes []string, encoder Encoder) {
+	registerEncoder(name, mimeTypes, encoder)
+}
+
+func registerEncoder(name string, mimeTypes []string, encoder Encoder) {
+	encoderMu.Lock()
+	defer encoderMu.Unlock()
+	encoders[name] = &encoderEntry{name: name, mimeTypes: mimeTypes, encoder: encoder}
+	for _, m := range mimeTypes {
+		mimeLookup[strings.ToLower(m)] = name
+	}
+}
+
+func getEncoder(name string) *encoderEntry {
+	encoderMu.RLock()
+	defer encoderMu.RUnlock()
+	return encoders[name]
+}
+
+// 根据路由设置和 Accept 选择编码，默认使用json
+func negotiateEncoder(c *gin.Context) string {
+	if meta := getRouteMeta(c.Request.Method, c.FullPath()); meta.format != "" {
+		return meta.format
+	}
+	accept := c.GetHeader("Accept")
+	// 浏览器的默认 Accept 包含 application/xml，直接使用json
+	if accept == "" || strings.Contains(accept, "text/html") {
+		return EncodingJSON
+	}
+
+	encoderMu.RLock()
+	defer encoderMu.RUnlock()
+	best := EncodingJSON
+	bestQ := 0.0
+	for _, part := range strings.Split(accept, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		mime := strings.ToLower(strings.TrimSpace(fields[0]))
+		q := 1.0
+		for _, f := range fields[1:] {
+			f = strings.TrimSpace(f)
+			if strings.HasPrefix(f, "q=") {
+				if v, err := strconv.ParseFloat(f[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		name := mimeLookup[mime]
+		if mime == "*/*" || mime == "application/*" {
+			name = EncodingJSON
+		}
+		if name != "" && q > bestQ {
+			best, bestQ = name, q
+		}
+	}
+	return best
+}
+
+// 设置路由的响应编码，不再根据 Accept 选择
+// @param string method 请求方法
+// @param string fullPath 完整的路由地址，与gin中的FullPath一致
+// @param string format 编码名称，如 EncodingXML
+func SetRouteFormat(method, fullPath, format string) {
+	if format == "" {
+		return
+	}
+	setRouteMeta(method, fullPath, func(meta *routeMeta) {
+		meta.format = format
+	})
+}
+
+// 设置路由的响应编码，url与Handle中的url一致
+func (s *GinServer) Format(method string, url string, format string) {
+	SetRouteFormat(method, joinPaths(s.Engine.BasePath(), apiURL(url)), format)
+}
+
+// 根据协商的编码输出
+func (r *Response) Render(v interface{}) {
+	name := negotiateEncoder(r.Ctx)
+	r.Ctx.Writer.Header().Add("Vary", "Accept")
+	entry := getEncoder(name)
+	if entry == nil || entry.encoder == nil {
+		r.Json(v)
+		return
+	}
+	r.Ctx.Render(r.StatusCode, entry.encoder(v))
+}
+
+// 将任意数据转换为json的通用结构，保证各种编码输出的字段与json一致
+func toGeneric(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var res interface{}
+	err = dec.Decode(&res)
+	return res, err
+}
+
+// xml编码，根节点为xml，map的key作为节点名称，数组元素使用item节点
+type xmlRender struct {
+	Data interface{}
+}
+
+var xmlContentType = []string{"application/xml; charset=utf-8"}
+
+var xmlNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+func (r xmlRender) WriteContentType(w http.ResponseWriter) {
+	if h := w.Header(); len(h["Content-Type"]) == 0 {
+		h["Content-Type"] = xmlContentType
+	}
+}
+
+func (r xmlRender) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	// 实现了xml.Marshaler的数据直接编码
+	if _, ok := r.Data.(xml.Marshaler); ok {
+		return xml.NewEncoder(w).Encode(r.Data)
+	}
+	data, err := toGeneric(r.Data)
+	if err != nil {
+		return err
+	}
+	buf := &bytes.Buffer{}
+	buf.WriteString(xml.Header)
+	writeXMLElement(buf, "xml", data)
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+func writeXMLElement(w io.Writer, name string, v interface{}) {
+	open, end := "<"+name+">", "</"+name+">"
+	if !xmlNameRegexp.MatchString(name) {
+		key := &bytes.Buffer{}
+		_ = xml.EscapeText(key, []byte(name))
+		open, end = `<item key="`+key.String()+`">`, "</item>"
+	}
+	_, _ = io.WriteString(w, open)
+	switch val := v.(type) {
+	case nil:
+	case map[string]interface{}:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			writeXMLElement(w, k, val[k])
+		}
+	case []interface{}:
+		for _, item := range val {
+			writeXMLElement(w, "item", item)
+		}
+	case string:
+		_ = xml.EscapeText(w, []byte(val))
+	case json.Number:
+		_, _ = io.WriteString(w, val.String())
+	case bool:
+		_, _ = io.WriteString(w, strconv.FormatBool(val))
+	}
+	_, _ = io.WriteString(w, end)
+}
+
+// protobuf编码，响应结构转换为 google.protobuf.Struct 后编码
+// Struct中的数字为double，超过2^53的整数会丢失精度，需要精确值时使用字符串
+type protobufRender struct {
+	Data interface{}
+}
+
+var protobufContentType = []string{"application/x-protobuf"}
+
+func (r protobufRender) WriteContentType(w http.ResponseWriter) {
+	if h := w.Header(); len(h["Content-Type"]) == 0 {
+		h["Content-Type"] = protobufContentType
+	}
+}
+
+func (r protobufRender) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	b, err := json.Marshal(r.Data)
+	if err != nil {
+		return err
+	}
+	st := &structpb.Struct{}
+	if err := protojson.Unmarshal(b, st); err != nil {
+		return err
+	}
+	b, err = proto.Marshal(st)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
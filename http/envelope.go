@@ -0,0 +1,157 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Mueat/frm-lib/errors"
+	"github.com/Mueat/frm-lib/trace"
+	"github.com/gin-gonic/gin"
+)
+
+// 响应结构配置
+type EnvelopeConfig struct {
+	CodeField      string                                // 错误码字段名称，默认：code
+	MsgField       string                                // 错误信息字段名称，默认：msg
+	DataField      string                                // 数据字段名称，默认：data
+	RequestIDField string                                // 请求ID字段名称，为空时不返回
+	TimestampField string                                // 秒级时间戳字段名称，为空时不返回
+	Extra          func(app *App) map[string]interface{} // 附加字段
+	HTTPStatus     bool                                  // 是否根据错误码返回对应的HTTP状态码，否则都返回200
+	StatusMap      map[int]int                           // 错误码对应的HTTP状态码，与默认的对应关系合并
+	DefaultStatus  int                                   // 未配置对应关系的错误码返回的HTTP状态码，默认：400
+	ProblemJSON    bool                                  // 错误使用RFC 7807格式（application/problem+json）返回，同时启用 HTTPStatus
+	ProblemType    string                                // problem的type前缀，后面拼接错误码，为空时使用 about:blank
+}
+
+// 默认的错误码与HTTP状态码的对应关系
+var defaultStatusMap = map[int]int{
+	errors.System:              http.StatusInternalServerError,
+	errors.Params:              http.StatusUnprocessableEntity,
+	errors.ModelNotFound:       http.StatusNotFound,
+	errors.Unauthorized:        http.StatusUnauthorized,
+	errors.Forbidden:           http.StatusForbidden,
+	errors.NotFound:            http.StatusNotFound,
+	errors.Conflict:            http.StatusConflict,
+	errors.PayloadTooLarge:     http.StatusRequestEntityTooLarge,
+	errors.InternalServerError: http.StatusInternalServerError,
+	errors.GatewayTimeout:      http.StatusGatewayTimeout,
+}
+
+var envelopeConf *EnvelopeConfig
+
+// 设置响应结构，未设置时使用 ApiResponse 并且都返回200
+func (s *GinServer) SetEnvelope(conf EnvelopeConfig) {
+	if conf.CodeField == "" {
+		conf.CodeField = "code"
+	}
+	if conf.MsgField == "" {
+		conf.MsgField = "msg"
+	}
+	if conf.DataField == "" {
+		conf.DataField = "data"
+	}
+	if conf.DefaultStatus == 0 {
+		conf.DefaultStatus = http.StatusBadRequest
+	}
+	if conf.ProblemJSON {
+		conf.HTTPStatus = true
+	}
+	statusMap := make(map[int]int, len(defaultStatusMap)+len(conf.StatusMap))
+	for k, v := range defaultStatusMap {
+		statusMap[k] = v
+	}
+	for k, v := range conf.StatusMap {
+		statusMap[k] = v
+	}
+	conf.StatusMap = statusMap
+	envelopeConf = &conf
+}
+
+// 错误码对应的HTTP状态码
+func errorStatus(code int) int {
+	if envelopeConf == nil || !envelopeConf.HTTPStatus || code == errors.OK {
+		return http.StatusOK
+	}
+	if status, ok := envelopeConf.StatusMap[code]; ok {
+		return status
+	}
+	return envelopeConf.DefaultStatus
+}
+
+// 生成响应内容
+func (r *Response) envelope(code int, msg string, data interface{}) interface{} {
+	conf := envelopeConf
+	if conf == nil {
+		return ApiResponse{
+			Code: code,
+			Msg:  msg,
+			Data: data,
+		}
+	}
+	res := map[string]interface{}{
+		conf.CodeField: code,
+		conf.MsgField:  msg,
+		conf.DataField: data,
+	}
+	r.extraFields(res)
+	return res
+}
+
+// 加入请求ID、时间戳等附加字段
+func (r *Response) extraFields(res map[string]interface{}) {
+	conf := envelopeConf
+	if conf.RequestIDField != "" {
+		res[conf.RequestIDField] = trace.FromContext(r.Ctx.Request.Context())
+	}
+	if conf.TimestampField != "" {
+		res[conf.TimestampField] = time.Now().Unix()
+	}
+	if conf.Extra != nil {
+		app := InitApp(r.Ctx)
+		for k, v := range conf.Extra(&app) {
+			res[k] = v
+		}
+	}
+}
+
+// 以RFC 7807格式返回错误
+func (r *Response) problem(code int, msg string) {
+	status := r.StatusCode
+	if status == 0 {
+		status = errorStatus(code)
+	}
+	typ := "about:blank"
+	if envelopeConf.ProblemType != "" {
+		typ = envelopeConf.ProblemType + strconv.Itoa(code)
+	}
+	res := map[string]interface{}{
+		"type":     typ,
+		"title":    errors.GetErrorMsg(code),
+		"status":   status,
+		"detail":   msg,
+		"instance": r.Ctx.Request.URL.Path,
+		"code":     code,
+	}
+	r.extraFields(res)
+
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(res); err != nil {
+		r.Ctx.Status(http.StatusInternalServerError)
+		return
+	}
+	r.Ctx.Data(status, "application/problem+json; charset=utf-8", buf.Bytes())
+}
+
+// 中断请求并返回错误
+// @param int status HTTP状态码，0表示根据错误码选择
+func abortWithError(c *gin.Context, status int, code int) {
+	resp := Response{Ctx: c, StatusCode: status}
+	resp.Error(code, resp.localize(code, "", nil))
+	c.Abort()
+}
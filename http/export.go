@@ -0,0 +1,369 @@
+package http
+
+import (
+	"archive/zip"
+	"bufio"
+	"database/sql/driver"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 导出时间的格式
+const ExportTimeFormat = "2006-01-02 15:04:05"
+
+// xlsx单个工作表的最大行数
+const xlsxMaxRows = 1048576
+
+var ErrExportTooManyRows = errors.New("export: too many rows for xlsx sheet")
+
+// 逐行导出
+type ExportWriter interface {
+	// 写入一行
+	WriteRow(values ...interface{}) error
+	// 结束导出，输出剩余内容
+	Close() error
+}
+
+// 处理指针和数据库类型，nil指针返回nil
+func exportValue(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	v = rv.Interface()
+	if _, ok := v.(time.Time); ok {
+		return v
+	}
+	if valuer, ok := v.(driver.Valuer); ok {
+		if val, err := valuer.Value(); err == nil {
+			return val
+		}
+	}
+	return v
+}
+
+// 将导出的值转换为字符串
+func exportString(v interface{}) string {
+	switch val := exportValue(v).(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case []byte:
+		return string(val)
+	case time.Time:
+		if val.IsZero() {
+			return ""
+		}
+		return val.Format(ExportTimeFormat)
+	case fmt.Stringer:
+		return val.String()
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+// 设置下载响应头
+func (r *Response) exportHeader(filename string, contentType string) {
+	h := r.Ctx.Writer.Header()
+	h.Set("Content-Type", contentType)
+	h.Set("Content-Disposition", contentDisposition("attachment", filename))
+	h.Set("Cache-Control", "no-store")
+	r.Ctx.Status(http.StatusOK)
+}
+
+// CSV导出
+type CSVWriter struct {
+	r     *Response
+	w     *csv.Writer
+	count int
+}
+
+// 开始CSV导出，写入UTF-8 BOM以便Excel正确识别中文
+// @param string filename 下载的文件名
+// @param []string headers 表头，为空时不写入
+func (r *Response) CSV(filename string, headers []string) (*CSVWriter, error) {
+	r.exportHeader(filename, "text/csv; charset=utf-8")
+	if _, err := r.Ctx.Writer.WriteString("\xEF\xBB\xBF"); err != nil {
+		return nil, err
+	}
+	cw := &CSVWriter{r: r, w: csv.NewWriter(r.Ctx.Writer)}
+	if len(headers) > 0 {
+		if err := cw.w.Write(headers); err != nil {
+			return nil, err
+		}
+	}
+	return cw, nil
+}
+
+// 写入一行，以 = + - @ 等开头的内容会加上 ' 前缀，避免在Excel中作为公式执行
+func (w *CSVWriter) WriteRow(values ...interface{}) error {
+	record := make([]string, len(values))
+	for i, v := range values {
+		record[i] = csvEscape(exportString(v))
+	}
+	if err := w.w.Write(record); err != nil {
+		return err
+	}
+	w.count++
+	// 定期输出，避免内容堆积在内存中
+	if w.count%1000 == 0 {
+		return w.flush()
+	}
+	return nil
+}
+
+// 转义可能被当做公式的内容，数字保持不变
+func csvEscape(s string) string {
+	if s == "" || !strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return s
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return s
+	}
+	return "'" + s
+}
+
+func (w *CSVWriter) flush() error {
+	w.w.Flush()
+	if err := w.w.Error(); err != nil {
+		return err
+	}
+	w.r.Ctx.Writer.Flush()
+	return nil
+}
+
+// 结束导出
+func (w *CSVWriter) Close() error {
+	return w.flush()
+}
+
+// XLSX导出，直接生成只有一个工作表的xlsx文件，所有单元格使用内联字符串，不依赖共享字符串表
+type XLSXWriter struct {
+	r     *Response
+	zw    *zip.Writer
+	sheet *bufio.Writer
+	rows  int
+}
+
+const (
+	xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/><Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/></Types>`
+	xlsxRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`
+	xlsxWorkbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets></workbook>`
+	xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/><Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/></Relationships>`
+	xlsxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><fonts count="1"><font><sz val="11"/><name val="Calibri"/></font></fonts><fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills><borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders><cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs><cellXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/></cellXfs><cellStyles count="1"><cellStyle name="Normal" xfId="0" builtinId="0"/></cellStyles></styleSheet>`
+	xlsxSheetHeader = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`
+	xlsxSheetFooter = `</sheetData></worksheet>`
+)
+
+// 开始XLSX导出
+// @param string filename 下载的文件名
+// @param string sheet 工作表名称，为空时使用Sheet1
+// @param []string headers 表头，为空时不写入
+func (r *Response) XLSX(filename string, sheet string, headers []string) (*XLSXWriter, error) {
+	if sheet == "" {
+		sheet = "Sheet1"
+	}
+	r.exportHeader(filename, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	zw := zip.NewWriter(r.Ctx.Writer)
+	sheetName := &strings.Builder{}
+	_ = xml.EscapeText(sheetName, []byte(sheet))
+	parts := []struct {
+		name    string
+		content string
+	}{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRels},
+		{"xl/workbook.xml", fmt.Sprintf(xlsxWorkbook, sheetName.String())},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+		{"xl/styles.xml", xlsxStyles},
+	}
+	for _, p := range parts {
+		f, err := zw.Create(p.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(f, p.content); err != nil {
+			return nil, err
+		}
+	}
+	f, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	xw := &XLSXWriter{r: r, zw: zw, sheet: bufio.NewWriterSize(f, 64*1024)}
+	if _, err := xw.sheet.WriteString(xlsxSheetHeader); err != nil {
+		return nil, err
+	}
+	if len(headers) > 0 {
+		values := make([]interface{}, len(headers))
+		for i, h := range headers {
+			values[i] = h
+		}
+		if err := xw.WriteRow(values...); err != nil {
+			return nil, err
+		}
+	}
+	return xw, nil
+}
+
+// 写入一行，数字和布尔值写为对应类型的单元格，其他值写为字符串
+func (w *XLSXWriter) WriteRow(values ...interface{}) error {
+	if w.rows >= xlsxMaxRows {
+		return ErrExportTooManyRows
+	}
+	w.rows++
+	row := strconv.Itoa(w.rows)
+	b := w.sheet
+	b.WriteString(`<row r="` + row + `">`)
+	for i, v := range values {
+		ref := xlsxColumn(i) + row
+		v = exportValue(v)
+		switch val := v.(type) {
+		case nil:
+			continue
+		case bool:
+			s := "0"
+			if val {
+				s = "1"
+			}
+			b.WriteString(`<c r="` + ref + `" t="b"><v>` + s + `</v></c>`)
+			continue
+		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+			// 超过15位的整数在Excel中会丢失精度，如订单号，写为字符串
+			if f := reflect.ValueOf(val).Convert(reflect.TypeOf(float64(0))).Float(); !math.IsNaN(f) && !math.IsInf(f, 0) && math.Abs(f) < 1e15 {
+				b.WriteString(`<c r="` + ref + `"><v>` + fmt.Sprint(val) + `</v></c>`)
+				continue
+			}
+		}
+		b.WriteString(`<c r="` + ref + `" t="inlineStr"><is><t xml:space="preserve">`)
+		if err := xml.EscapeText(b, []byte(exportString(v))); err != nil {
+			return err
+		}
+		b.WriteString(`</t></is></c>`)
+	}
+	_, err := b.WriteString(`</row>`)
+	return err
+}
+
+// 结束导出
+func (w *XLSXWriter) Close() error {
+	if _, err := w.sheet.WriteString(xlsxSheetFooter); err != nil {
+		return err
+	}
+	if err := w.sheet.Flush(); err != nil {
+		return err
+	}
+	if err := w.zw.Close(); err != nil {
+		return err
+	}
+	w.r.Ctx.Writer.Flush()
+	return nil
+}
+
+// 列序号转换为Excel列名，0 => A，26 => AA
+func xlsxColumn(i int) string {
+	name := ""
+	for i >= 0 {
+		name = string(rune('A'+i%26)) + name
+		i = i/26 - 1
+	}
+	return name
+}
+
+// 导出中途出错时中断连接，避免客户端收到内容不完整但格式正确的文件
+func (r *Response) abortExport() {
+	r.Ctx.Abort()
+	if conn, _, err := r.Ctx.Writer.Hijack(); err == nil {
+		_ = conn.Close()
+		return
+	}
+	// 不支持Hijack时（如HTTP/2）由net/http中断连接
+	panic(http.ErrAbortHandler)
+}
+
+// 使用游标逐行导出查询结果，不会一次性加载全部数据
+// @param ExportWriter w 导出，由 Response.CSV 或 Response.XLSX 创建
+// @param *gorm.DB query 查询，如 app.DefaultDB().Model(&Order{}).Where("status = ?", 1)
+// @param interface{} dest 每行数据扫描的目标，如 &Order{}，每行扫描前会重置为零值
+// @param func() []interface{} fn 将dest转换为一行数据
+func ExportQuery(w ExportWriter, query *gorm.DB, dest interface{}, fn func() []interface{}) error {
+	rows, err := query.Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	rv := reflect.ValueOf(dest)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.New("export: dest must be a non-nil pointer")
+	}
+	zero := reflect.Zero(rv.Elem().Type())
+	for rows.Next() {
+		rv.Elem().Set(zero)
+		if err := query.ScanRows(rows, dest); err != nil {
+			return err
+		}
+		if err := w.WriteRow(fn()...); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// 导出查询结果为CSV
+// 导出时间较长时需要通过 GinServer.Limit 或 Router.Timeout 调整超时时间
+// 查询出错时会中断连接，返回错误后不需要再输出响应
+//
+//	var order Order
+//	app.ExportCSV("订单.csv", []string{"订单号", "金额", "下单时间"}, app.DefaultDB().Model(&Order{}), &order, func() []interface{} {
+//		return []interface{}{order.OrderNo, order.Amount, order.CreatedAt}
+//	})
+func (a *App) ExportCSV(filename string, headers []string, query *gorm.DB, dest interface{}, fn func() []interface{}) error {
+	w, err := a.Response.CSV(filename, headers)
+	if err != nil {
+		return err
+	}
+	if err := ExportQuery(w, query, dest, fn); err != nil {
+		a.LogError().Err(err).Str("type", ErrPack).Str("name", "export").Str("method", "ExportCSV").Str("request_id", a.RequestID()).Send()
+		a.Response.abortExport()
+		return err
+	}
+	return w.Close()
+}
+
+// 导出查询结果为XLSX
+func (a *App) ExportXLSX(filename string, headers []string, query *gorm.DB, dest interface{}, fn func() []interface{}) error {
+	w, err := a.Response.XLSX(filename, "", headers)
+	if err != nil {
+		return err
+	}
+	if err := ExportQuery(w, query, dest, fn); err != nil {
+		a.LogError().Err(err).Str("type", ErrPack).Str("name", "export").Str("method", "ExportXLSX").Str("request_id", a.RequestID()).Send()
+		a.Response.abortExport()
+		return err
+	}
+	return w.Close()
+}
@@ -0,0 +1,82 @@
+package http
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Mueat/frm-lib/errors"
+)
+
+// 生成 Content-Disposition，同时设置ASCII文件名和UTF-8文件名，兼容中文文件名
+// @param string disposition attachment 或 inline
+// @param string filename 文件名
+func contentDisposition(disposition string, filename string) string {
+	fallback := make([]rune, 0, len(filename))
+	for _, r := range filename {
+		if r < 0x20 || r > 0x7e || r == '"' || r == '\\' || r == '%' {
+			r = '_'
+		}
+		fallback = append(fallback, r)
+	}
+	encoded := strings.ReplaceAll(url.QueryEscape(filename), "+", "%20")
+	return disposition + "; filename=\"" + string(fallback) + "\"; filename*=UTF-8''" + encoded
+}
+
+// 输出文件，支持Range断点续传和 If-Modified-Since
+// 文件不存在时返回 errors.NotFound
+func (r *Response) File(path string) {
+	f, err := os.Open(path)
+	if err != nil {
+		r.Error(errors.NotFound, errors.GetErrorMsg(errors.NotFound))
+		return
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil || stat.IsDir() {
+		r.Error(errors.NotFound, errors.GetErrorMsg(errors.NotFound))
+		return
+	}
+	http.ServeContent(r.Ctx.Writer, r.Ctx.Request, stat.Name(), stat.ModTime(), f)
+}
+
+// 以附件形式下载文件，支持Range断点续传
+// @param string path 文件路径
+// @param string filename 下载的文件名，为空时使用path中的文件名
+func (r *Response) Attachment(path string, filename string) {
+	if filename == "" {
+		filename = filepath.Base(path)
+	}
+	r.Ctx.Header("Content-Disposition", contentDisposition("attachment", filename))
+	r.File(path)
+}
+
+// 以附件形式下载内容，支持Range断点续传，Content-Type根据文件扩展名判断
+// @param string filename 下载的文件名
+// @param time.Time modtime 修改时间，用于 If-Modified-Since，零值表示不设置
+// @param io.ReadSeeker content 文件内容
+func (r *Response) AttachmentContent(filename string, modtime time.Time, content io.ReadSeeker) {
+	r.Ctx.Header("Content-Disposition", contentDisposition("attachment", filename))
+	http.ServeContent(r.Ctx.Writer, r.Ctx.Request, filename, modtime, content)
+}
+
+// 输出文件
+func (a *App) File(path string) {
+	a.Response.File(path)
+}
+
+// 以附件形式下载文件
+//
+//	app.Attachment("./storage/orders.pdf", "订单明细.pdf")
+func (a *App) Attachment(path string, filename string) {
+	a.Response.Attachment(path, filename)
+}
+
+// 以附件形式下载内容
+func (a *App) AttachmentContent(filename string, modtime time.Time, content io.ReadSeeker) {
+	a.Response.AttachmentContent(filename, modtime, content)
+}
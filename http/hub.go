@@ -0,0 +1,222 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/Mueat/frm-lib/cache"
+	elog "github.com/Mueat/frm-lib/log"
+	"github.com/Mueat/frm-lib/trace"
+)
+
+const (
+	DefaultHubChannel = "ws:hub"
+)
+
+// hub配置
+type HubConfig struct {
+	Redis   *cache.Pools // 配置后通过redis发布订阅在多个实例之间广播，不使用其中的context，订阅在调用 Hub.Close 后停止
+	Channel string       // redis频道名称，默认：ws:hub
+}
+
+// 管理websocket连接、房间和广播
+type Hub struct {
+	conf      HubConfig
+	nodeID    string
+	redis     *cache.Pools // 使用hub自己的context，不受传入的请求context影响
+	mu        sync.RWMutex
+	conns     map[*WSConn]map[string]bool
+	rooms     map[string]map[*WSConn]bool
+	ctx       context.Context
+	cancel    context.CancelFunc
+	closeOnce sync.Once
+}
+
+// 通过redis传递的广播消息
+type hubMessage struct {
+	Node string `json:"node"`
+	Room string `json:"room"`
+	Type int    `json:"type"`
+	Data []byte `json:"data"`
+}
+
+// 创建hub
+func NewHub(conf HubConfig) *Hub {
+	if conf.Channel == "" {
+		conf.Channel = DefaultHubChannel
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	h := &Hub{
+		conf:   conf,
+		nodeID: trace.NewID(),
+		conns:  make(map[*WSConn]map[string]bool),
+		rooms:  make(map[string]map[*WSConn]bool),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	if conf.Redis != nil {
+		h.redis = conf.Redis.WithContext(ctx)
+		go h.subscribe()
+	}
+	return h
+}
+
+// 添加连接
+func (h *Hub) add(conn *WSConn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.conns[conn]; !ok {
+		h.conns[conn] = make(map[string]bool)
+	}
+}
+
+// 加入房间
+func (h *Hub) Join(conn *WSConn, rooms ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	// 连接关闭时先关闭Done再从hub移除，在锁内检查避免关闭后再加入
+	select {
+	case <-conn.Done():
+		return
+	default:
+	}
+	joined, ok := h.conns[conn]
+	if !ok {
+		joined = make(map[string]bool)
+		h.conns[conn] = joined
+	}
+	for _, room := range rooms {
+		if h.rooms[room] == nil {
+			h.rooms[room] = make(map[*WSConn]bool)
+		}
+		h.rooms[room][conn] = true
+		joined[room] = true
+	}
+}
+
+// 离开房间
+func (h *Hub) Leave(conn *WSConn, rooms ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, room := range rooms {
+		h.leave(conn, room)
+	}
+}
+
+func (h *Hub) leave(conn *WSConn, room string) {
+	if members, ok := h.rooms[room]; ok {
+		delete(members, conn)
+		if len(members) == 0 {
+			delete(h.rooms, room)
+		}
+	}
+	if joined, ok := h.conns[conn]; ok {
+		delete(joined, room)
+	}
+}
+
+// 连接关闭时移除
+func (h *Hub) remove(conn *WSConn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for room := range h.conns[conn] {
+		h.leave(conn, room)
+	}
+	delete(h.conns, conn)
+}
+
+// 本实例的连接数
+func (h *Hub) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.conns)
+}
+
+// 房间中的本实例连接数
+func (h *Hub) Count(room string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.rooms[room])
+}
+
+// 向房间广播消息，配置了redis时同时广播到其他实例
+// @param string room 房间名称
+// @param int msgType 消息类型，WSTextMessage 或 WSBinaryMessage
+// @param []byte data 消息内容
+func (h *Hub) Broadcast(room string, msgType int, data []byte) error {
+	h.broadcastLocal(room, msgType, data)
+	if h.redis == nil {
+		return nil
+	}
+	msg, err := json.Marshal(hubMessage{Node: h.nodeID, Room: room, Type: msgType, Data: data})
+	if err != nil {
+		return err
+	}
+	return h.redis.Publish(h.conf.Channel, string(msg))
+}
+
+// 向房间广播json消息
+func (h *Hub) BroadcastJSON(room string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return h.Broadcast(room, WSTextMessage, data)
+}
+
+// 向本实例中房间内的连接发送消息
+func (h *Hub) broadcastLocal(room string, msgType int, data []byte) {
+	h.mu.RLock()
+	members := make([]*WSConn, 0, len(h.rooms[room]))
+	for conn := range h.rooms[room] {
+		members = append(members, conn)
+	}
+	h.mu.RUnlock()
+	// 发送缓冲区满时会关闭连接并从hub中移除，不能持有锁
+	for _, conn := range members {
+		_ = conn.Send(msgType, data)
+	}
+}
+
+// 订阅其他实例的广播
+func (h *Hub) subscribe() {
+	pubsub := h.redis.Subscribe(h.conf.Channel)
+	defer pubsub.Close()
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-h.ctx.Done():
+			return
+		case m, ok := <-ch:
+			if !ok {
+				return
+			}
+			var msg hubMessage
+			if err := json.Unmarshal([]byte(m.Payload), &msg); err != nil {
+				elog.Error().Err(err).Str("type", ErrPack).Str("name", "hub").Str("method", "subscribe").Str("payload", m.Payload).Send()
+				continue
+			}
+			if msg.Node == h.nodeID {
+				continue
+			}
+			h.broadcastLocal(msg.Room, msg.Type, msg.Data)
+		}
+	}
+}
+
+// 关闭hub，停止订阅并关闭所有连接，可以重复调用
+func (h *Hub) Close() {
+	h.closeOnce.Do(func() {
+		h.cancel()
+		h.mu.RLock()
+		conns := make([]*WSConn, 0, len(h.conns))
+		for conn := range h.conns {
+			conns = append(conns, conn)
+		}
+		h.mu.RUnlock()
+		for _, conn := range conns {
+			conn.closeWith(WSCloseGoingAway, "")
+		}
+	})
+}
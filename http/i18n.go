@@ -0,0 +1,134 @@
+package http
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Mueat/frm-lib/errors"
+	"github.com/Mueat/frm-lib/util"
+)
+
+const localeCtxKey = "$locale"
+
+// 多语言配置
+type I18nConfig struct {
+	DefaultLocale string // 默认语言，无法确定请求语言时使用，为空时使用 errors.Errors
+	QueryParam    string // 指定语言的查询参数，默认：lang
+	JWTKey        string // 鉴权中间件保存 *util.JWT 的key，默认：jwt
+	JWTField      string // JWT的Extra中保存语言的字段，默认：locale
+}
+
+var i18nConf *I18nConfig
+
+// 启用多语言错误信息，按查询参数、JWT、Accept-Language 的顺序确定请求的语言
+// 语言包通过 errors.LoadLocaleDir、errors.LoadLocaleFile 或 errors.AddLocaleErrors 加载
+func (s *GinServer) SetI18n(conf I18nConfig) {
+	if conf.QueryParam == "" {
+		conf.QueryParam = "lang"
+	}
+	if conf.JWTKey == "" {
+		conf.JWTKey = "jwt"
+	}
+	if conf.JWTField == "" {
+		conf.JWTField = "locale"
+	}
+	i18nConf = &conf
+}
+
+// 获取请求的语言，未启用多语言或者没有匹配的语言时返回默认语言
+func (r *Response) Locale() string {
+	if i18nConf == nil {
+		return ""
+	}
+	c := r.Ctx
+	if locale := c.GetString(localeCtxKey); locale != "" {
+		return locale
+	}
+	locale := errors.MatchLocale(c.Query(i18nConf.QueryParam))
+	if locale == "" {
+		locale = r.jwtLocale()
+	}
+	if locale == "" {
+		for _, tag := range parseAcceptLanguage(c.GetHeader("Accept-Language")) {
+			if locale = errors.MatchLocale(tag); locale != "" {
+				break
+			}
+		}
+	}
+	if locale == "" {
+		locale = i18nConf.DefaultLocale
+	}
+	c.Set(localeCtxKey, locale)
+	return locale
+}
+
+// 从JWT中获取语言
+func (r *Response) jwtLocale() string {
+	v, ok := r.Ctx.Get(i18nConf.JWTKey)
+	if !ok {
+		return ""
+	}
+	tk, ok := v.(*util.JWT)
+	if !ok || tk == nil {
+		return ""
+	}
+	extra, ok := tk.Extra.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	locale, _ := extra[i18nConf.JWTField].(string)
+	return errors.MatchLocale(locale)
+}
+
+// 按权重解析 Accept-Language
+func parseAcceptLanguage(header string) []string {
+	type tag struct {
+		name string
+		q    float64
+	}
+	tags := make([]tag, 0)
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		name := strings.TrimSpace(fields[0])
+		if name == "" || name == "*" {
+			continue
+		}
+		q := 1.0
+		for _, f := range fields[1:] {
+			f = strings.TrimSpace(f)
+			if strings.HasPrefix(f, "q=") {
+				if v, err := strconv.ParseFloat(f[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if q > 0 {
+			tags = append(tags, tag{name: name, q: q})
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool {
+		return tags[i].q > tags[j].q
+	})
+	res := make([]string, len(tags))
+	for i, t := range tags {
+		res[i] = t.name
+	}
+	return res
+}
+
+// 获取错误码对应的信息，启用多语言时使用请求语言的信息，并替换占位符
+// msg为空或者为默认信息时才会使用语言包中的信息
+func (r *Response) localize(code int, msg string, params map[string]interface{}) string {
+	if i18nConf != nil && (msg == "" || msg == errors.GetErrorMsg(code)) {
+		msg = errors.GetLocaleMsg(r.Locale(), code)
+	} else if msg == "" {
+		msg = errors.GetErrorMsg(code)
+	}
+	return errors.Format(msg, params)
+}
+
+// 获取请求的语言
+func (a *App) Locale() string {
+	return a.Response.Locale()
+}
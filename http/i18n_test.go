@@ -0,0 +1,57 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/Mueat/frm-lib/errors"
+	"github.com/gin-gonic/gin"
+)
+
+func TestAppErrorI18n(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := &GinServer{Engine: gin.New()}
+	s.SetI18n(I18nConfig{})
+	t.Cleanup(func() {
+		i18nConf = nil
+	})
+	const (
+		translated   = 990001
+		untranslated = 990002
+	)
+	errors.AddLocaleErrors("zh-CN", map[int]string{
+		errors.NotFound: "资源不存在",
+		translated:      "{field}格式错误",
+	})
+	s.Handle(http.MethodGet, "/error", func(app *App) {
+		app.Error(int(app.GetQueryInt64("code", 0)), map[string]interface{}{"field": "name"})
+	})
+
+	cases := []struct {
+		name string
+		code int
+		lang string
+		msg  string
+	}{
+		{"catalog", errors.NotFound, "zh-CN", "资源不存在"},
+		{"catalog params", translated, "zh", "name格式错误"},
+		{"no catalog for locale", errors.NotFound, "fr", "Not Found"},
+		{"no catalog entry", errors.Conflict, "zh-CN", "Conflict"},
+		{"unknown code", untranslated, "zh-CN", "Unkonw Error"},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/error?code="+strconv.Itoa(c.code)+"&lang="+c.lang, nil)
+		s.Engine.ServeHTTP(w, req)
+		var resp ApiResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s: %v %s", c.name, err, w.Body.String())
+		}
+		if resp.Code != c.code || resp.Msg != c.msg {
+			t.Errorf("%s: expected %d %q, got %d %q", c.name, c.code, c.msg, resp.Code, resp.Msg)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package http
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/Mueat/frm-lib/cache"
+	"github.com/Mueat/frm-lib/errors"
+	elog "github.com/Mueat/frm-lib/log"
+	"github.com/Mueat/frm-lib/util"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	DefaultIdempotencyHeader      = "Idempotency-Key"
+	DefaultIdempotencyExpire      = 86400
+	DefaultIdempotencyLockTimeout = 60
+	DefaultIdempotencyPrefix      = "idempotency:"
+)
+
+// 幂等配置
+type IdempotencyConfig struct {
+	HeaderName  string // 幂等key的请求头，默认：Idempotency-Key
+	Expire      int64  // 响应保存时间，单位：秒，默认：86400秒
+	LockTimeout int64  // 请求处理中的锁定时间，单位：秒，默认：60秒
+	Required    bool   // 是否必须携带幂等key
+	Prefix      string // 存储key的前缀，默认：idempotency:
+	// 幂等key的作用域，必须设置，一般返回当前用户ID，避免其他用户使用相同的key获取到保存的响应
+	// 返回空字符串时表示没有身份信息，不做幂等处理；允许所有调用方共享时返回固定的字符串
+	Scope func(app *App) string
+}
+
+// 保存的响应
+type idempotentResponse struct {
+	Hash   string      `json:"hash"`
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+}
+
+// 记录响应内容的ResponseWriter
+type teeWriter struct {
+	gin.ResponseWriter
+	body *bytes.Buffer
+}
+
+func (w *teeWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *teeWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// 幂等中间件，用于支付、下单等修改数据的接口
+// 相同的幂等key和请求体会直接返回第一次请求的响应；请求体不同或者第一次请求还在处理中时返回 errors.Conflict
+// 只保存HTTP状态码为2xx并且没有返回错误码的响应，失败的请求可以使用相同的幂等key重试
+// @param IdempotencyConfig conf 配置
+// @param cache.Store store 存储，一般为 cache.GetRedis 返回的连接
+func Idempotency(conf IdempotencyConfig, store cache.Store) RouterFun {
+	if conf.Scope == nil {
+		panic("http: idempotency requires a Scope")
+	}
+	if conf.HeaderName == "" {
+		conf.HeaderName = DefaultIdempotencyHeader
+	}
+	if conf.Expire <= 0 {
+		conf.Expire = DefaultIdempotencyExpire
+	}
+	if conf.LockTimeout <= 0 {
+		conf.LockTimeout = DefaultIdempotencyLockTimeout
+	}
+	if conf.Prefix == "" {
+		conf.Prefix = DefaultIdempotencyPrefix
+	}
+
+	return func(app *App) {
+		c := app.GetContext()
+		idemKey := c.GetHeader(conf.HeaderName)
+		if idemKey == "" || !isUnsafeMethod(c.Request.Method) {
+			if conf.Required && isUnsafeMethod(c.Request.Method) {
+				app.Response.Error(errors.Params, conf.HeaderName+" required")
+				app.Abort()
+			}
+			return
+		}
+
+		scope := conf.Scope(app)
+		if scope == "" {
+			return
+		}
+		key := conf.Prefix + util.Md5(c.Request.Method+" "+c.FullPath()+" "+scope+" "+idemKey)
+		lockKey := key + ":lock"
+		hash := requestHash(c)
+
+		if replayIdempotent(app, store.GetString(key), hash) {
+			return
+		}
+		// 锁的值为随机token，释放时只删除自己持有的锁
+		lockToken, err := util.GenerateNonce(32)
+		if err != nil {
+			app.Error(errors.System)
+			app.Abort()
+			return
+		}
+		locked, err := store.SetNXEX(lockKey, lockToken, time.Duration(conf.LockTimeout)*time.Second)
+		if err != nil {
+			app.Error(errors.System)
+			app.Abort()
+			return
+		}
+		if !locked {
+			// 锁定失败时第一次请求可能刚好处理完成
+			if !replayIdempotent(app, store.GetString(key), hash) {
+				app.Response.Error(errors.Conflict, "request with the same "+conf.HeaderName+" is in progress")
+				app.Abort()
+			}
+			return
+		}
+		defer func() {
+			_, _ = store.DelIfEqual(lockKey, lockToken)
+		}()
+
+		w := &teeWriter{ResponseWriter: c.Writer, body: &bytes.Buffer{}}
+		c.Writer = w
+		app.Next()
+		c.Writer = w.ResponseWriter
+
+		// 错误的响应不保存，允许客户端重试
+		status := c.Writer.Status()
+		if status < http.StatusOK || status >= http.StatusMultipleChoices {
+			return
+		}
+		if code, ok := c.Get(respCodeCtxKey); ok && code != errors.OK {
+			return
+		}
+		resp := idempotentResponse{
+			Hash:   hash,
+			Status: c.Writer.Status(),
+			Header: c.Writer.Header().Clone(),
+			Body:   w.body.Bytes(),
+		}
+		b, err := json.Marshal(resp)
+		if err == nil {
+			err = store.Set(key, string(b), time.Duration(conf.Expire)*time.Second)
+		}
+		if err != nil {
+			elog.Error().Err(err).Str("type", ErrPack).Str("name", "idempotency").Str("method", "Set").Str("key", idemKey).Send()
+		}
+	}
+}
+
+// 返回保存的响应，返回false表示没有保存的响应
+func replayIdempotent(app *App, saved string, hash string) bool {
+	if saved == "" {
+		return false
+	}
+	resp := idempotentResponse{}
+	if err := json.Unmarshal([]byte(saved), &resp); err != nil {
+		return false
+	}
+	if resp.Hash != hash {
+		app.Response.Error(errors.Conflict, "request body does not match the original request")
+		app.Abort()
+		return true
+	}
+	c := app.GetContext()
+	h := c.Writer.Header()
+	for k, v := range resp.Header {
+		if k == "Date" || k == "Content-Length" || k == "Set-Cookie" {
+			continue
+		}
+		h[k] = v
+	}
+	h.Set("Idempotent-Replayed", "true")
+	c.Status(resp.Status)
+	_, _ = c.Writer.Write(resp.Body)
+	app.Abort()
+	return true
+}
+
+// 计算请求体的hash
+func requestHash(c *gin.Context) string {
+	var body []byte
+	if v, ok := c.Get("body"); ok {
+		body, _ = v.([]byte)
+	}
+	if len(body) == 0 && c.Request.Body != nil {
+		b, err := ioutil.ReadAll(c.Request.Body)
+		if err == nil {
+			body = b
+			c.Request.Body = ioutil.NopCloser(bytes.NewBuffer(b))
+		}
+	}
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
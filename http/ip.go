@@ -0,0 +1,147 @@
+package http
+
+import (
+	"net"
+	"strings"
+
+	elog "github.com/Mueat/frm-lib/log"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// gin.Context中保存客户端IP的key
+	clientIPCtxKey = "$client_ip"
+)
+
+// 未配置 TrustedProxies 时只信任本机代理
+var defaultTrustedProxies = []string{"127.0.0.0/8", "::1/128"}
+
+// 信任的代理网段
+var trustedProxies []*net.IPNet
+
+// 解析信任的代理，支持CIDR和单个IP，错误的配置记录日志后跳过
+func parseTrustedProxies(proxies []string) []*net.IPNet {
+	if proxies == nil {
+		proxies = defaultTrustedProxies
+	}
+	nets := make([]*net.IPNet, 0, len(proxies))
+	for _, p := range proxies {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if !strings.Contains(p, "/") {
+			if ip := net.ParseIP(p); ip != nil {
+				if ip.To4() != nil {
+					p += "/32"
+				} else {
+					p += "/128"
+				}
+			}
+		}
+		_, n, err := net.ParseCIDR(p)
+		if err != nil {
+			elog.Error().Err(err).Str("type", ErrPack).Str("name", "server").Str("method", "parseTrustedProxies").Str("proxy", p).Send()
+			continue
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}
+
+// 是否是信任的代理
+func isTrustedProxy(ip net.IP) bool {
+	for _, n := range trustedProxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// 获取客户端IP
+// 只有直接连接的地址是信任的代理时才会读取代理请求头，
+// 依次使用 Forwarded、X-Forwarded-For、X-Real-IP，从右向左跳过信任的代理，返回第一个不信任的地址
+func ClientIP(c *gin.Context) string {
+	if ip := c.GetString(clientIPCtxKey); ip != "" {
+		return ip
+	}
+	ip := resolveClientIP(c)
+	c.Set(clientIPCtxKey, ip)
+	return ip
+}
+
+func resolveClientIP(c *gin.Context) string {
+	remote := c.Request.RemoteAddr
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		remote = host
+	}
+	remoteIP := net.ParseIP(remote)
+	if remoteIP == nil || !isTrustedProxy(remoteIP) {
+		return remote
+	}
+
+	h := c.Request.Header
+	// Forwarded 中没有for值时，如只有 proto=https，继续使用 X-Forwarded-For
+	hops := parseForwarded(h.Values("Forwarded"))
+	if len(hops) == 0 {
+		for _, v := range h.Values("X-Forwarded-For") {
+			for _, hop := range strings.Split(v, ",") {
+				hops = append(hops, strings.TrimSpace(hop))
+			}
+		}
+	}
+	if len(hops) == 0 {
+		if realIP := strings.TrimSpace(h.Get("X-Real-IP")); realIP != "" {
+			hops = []string{realIP}
+		}
+	}
+
+	last := remote
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(stripPort(hops[i]))
+		if ip == nil {
+			// 无法识别的地址，如 unknown 或者混淆的标识，使用最后一个可信的地址
+			return last
+		}
+		last = ip.String()
+		if !isTrustedProxy(ip) {
+			return last
+		}
+	}
+	return last
+}
+
+// 解析RFC 7239 Forwarded请求头中的for值
+// 如：Forwarded: for=192.0.2.60;proto=http;by=203.0.113.43, for="[2001:db8:cafe::17]:4711"
+func parseForwarded(values []string) []string {
+	hops := make([]string, 0)
+	for _, v := range values {
+		for _, elem := range strings.Split(v, ",") {
+			for _, pair := range strings.Split(elem, ";") {
+				kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+				if len(kv) != 2 || !strings.EqualFold(kv[0], "for") {
+					continue
+				}
+				hops = append(hops, strings.Trim(kv[1], "\""))
+			}
+		}
+	}
+	return hops
+}
+
+// 去掉地址中的端口以及ipv6的中括号
+func stripPort(addr string) string {
+	if strings.HasPrefix(addr, "[") {
+		if end := strings.Index(addr, "]"); end > 0 {
+			return addr[1:end]
+		}
+		return addr
+	}
+	if strings.Count(addr, ":") == 1 {
+		if host, _, err := net.SplitHostPort(addr); err == nil {
+			return host
+		}
+	}
+	return addr
+}
@@ -0,0 +1,153 @@
+package http
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Mueat/frm-lib/errors"
+	elog "github.com/Mueat/frm-lib/log"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+)
+
+const (
+	DefaultLogDebugHeader = "X-Debug-Log"
+	DefaultLogDebugMaxTTL = 3600
+)
+
+// 单个请求开启debug日志的配置
+type LogDebugConfig struct {
+	Header string // 请求头名称，默认：X-Debug-Log
+	Secret string // 签名秘钥
+	MaxTTL int64  // 签名的最长有效期，单位：秒，默认：3600秒
+}
+
+// 修改日志等级的参数
+type logLevelParams struct {
+	Name     string `json:"name"`     // 日志名称，为空时修改全部日志
+	Level    string `json:"level"`    // 日志等级，如 debug、info
+	Duration int64  `json:"duration"` // 生效时长，单位：秒，0表示一直生效
+}
+
+// 允许通过签名请求头为单个请求开启debug日志，请求头的值通过 SignLogDebug 生成
+// 开启后 App.Log 等方法获取的日志处理器以及sql日志会输出debug日志
+//
+//	curl -H "X-Debug-Log: $(token)" https://api.example.com/orders
+func (s *GinServer) SetLogDebug(conf LogDebugConfig) {
+	if conf.Header == "" {
+		conf.Header = DefaultLogDebugHeader
+	}
+	if conf.MaxTTL <= 0 {
+		conf.MaxTTL = DefaultLogDebugMaxTTL
+	}
+	s.Engine.Use(func(c *gin.Context) {
+		value := c.GetHeader(conf.Header)
+		if value == "" || conf.Secret == "" {
+			return
+		}
+		if !verifyLogDebug(conf, value) {
+			elog.Warn().Str("type", ErrPack).Str("name", "server").Str("method", "SetLogDebug").Str("ip", ClientIP(c)).Msg("invalid debug log signature")
+			return
+		}
+		c.Request = c.Request.WithContext(elog.WithDebug(c.Request.Context()))
+	})
+}
+
+// 生成开启debug日志的请求头的值，格式：过期时间戳.签名
+// @param string secret 签名秘钥，与 LogDebugConfig.Secret 相同
+// @param int64 ttl 有效期，单位：秒
+func SignLogDebug(secret string, ttl int64) string {
+	expires := strconv.FormatInt(time.Now().Unix()+ttl, 10)
+	return expires + "." + logDebugSign(secret, expires)
+}
+
+func logDebugSign(secret string, expires string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// 校验请求头的签名和有效期
+func verifyLogDebug(conf LogDebugConfig, value string) bool {
+	parts := strings.SplitN(value, ".", 2)
+	if len(parts) != 2 {
+		return false
+	}
+	expires, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return false
+	}
+	now := time.Now().Unix()
+	if expires < now || expires-now > conf.MaxTTL {
+		return false
+	}
+	return hmac.Equal([]byte(parts[1]), []byte(logDebugSign(conf.Secret, parts[0])))
+}
+
+// 注册修改日志等级的管理接口，必须设置鉴权中间件，如 Signature 或者结合 Perm 的权限校验
+//
+//	GET    url                                             获取全部日志的等级
+//	PUT    url {"name": "", "level": "debug", "duration": 600}  修改日志等级，到期后自动恢复
+//	DELETE url?name=                                       恢复为配置的等级
+//
+// @param string url 接口地址
+// @param RouterFun auth 鉴权中间件
+// @param ...RouterFun middlewares 其他中间件
+func (s *GinServer) LogLevel(url string, auth RouterFun, middlewares ...RouterFun) {
+	if auth == nil {
+		panic("http: log level admin requires an auth middleware")
+	}
+	route := func(h RouterFun) []RouterFun {
+		handlers := append([]RouterFun{auth}, middlewares...)
+		return append(handlers, h)
+	}
+	s.Handle(http.MethodGet, url, route(getLogLevels)...)
+	s.Handle(http.MethodPut, url, route(setLogLevel)...)
+	s.Handle(http.MethodDelete, url, route(resetLogLevel)...)
+}
+
+func getLogLevels(app *App) {
+	app.Resp(elog.Levels(), nil)
+}
+
+func setLogLevel(app *App) {
+	params := logLevelParams{}
+	if err := app.Bind(&params); err != nil {
+		app.Resp(nil, err)
+		return
+	}
+	if params.Level == "" {
+		app.Resp(nil, errors.Code(errors.Params).With("level", params.Level))
+		return
+	}
+	if params.Duration < 0 {
+		app.Resp(nil, errors.Code(errors.Params).With("duration", params.Duration))
+		return
+	}
+	level, err := zerolog.ParseLevel(strings.ToLower(params.Level))
+	if err != nil {
+		app.Resp(nil, errors.Wrap(err, errors.Params).With("level", params.Level))
+		return
+	}
+	if err := elog.SetLevel(params.Name, level, time.Duration(params.Duration)*time.Second); err != nil {
+		app.Resp(nil, errors.Wrap(err, errors.NotFound))
+		return
+	}
+	elog.Warn().Str("type", ErrPack).Str("name", "server").Str("method", "LogLevel").Str("logger", params.Name).Str("new_level", level.String()).Int64("duration", params.Duration).Str("ip", app.GetIP()).Msg("log level changed")
+	app.Resp(elog.Levels(), nil)
+}
+
+func resetLogLevel(app *App) {
+	name := app.GetQuery("name", "")
+	if err := elog.ResetLevel(name); err != nil {
+		app.Resp(nil, errors.Wrap(err, errors.NotFound))
+		return
+	}
+	elog.Warn().Str("type", ErrPack).Str("name", "server").Str("method", "LogLevel").Str("logger", name).Str("ip", app.GetIP()).Msg("log level reset")
+	app.Resp(elog.Levels(), nil)
+}
@@ -0,0 +1,24 @@
+package http
+
+import (
+	elog "github.com/Mueat/frm-lib/log"
+	"github.com/Mueat/frm-lib/report"
+	"github.com/gin-gonic/gin"
+)
+
+var reporter *report.Reporter
+
+// 设置错误上报，捕获的panic以及 Resp 返回的需要上报的错误会发送到 reporter
+func (s *GinServer) SetReporter(r *report.Reporter) {
+	reporter = r
+}
+
+// 上报时附加的请求信息
+func reportFields(c *gin.Context) map[string]interface{} {
+	return map[string]interface{}{
+		"method": c.Request.Method,
+		"url":    elog.MaskText(c.Request.URL.String()),
+		"route":  c.FullPath(),
+		"ip":     ClientIP(c),
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"errors"
 	"mime/multipart"
-	"strings"
 
 	"github.com/Mueat/frm-lib/log"
 	"github.com/gin-gonic/gin"
@@ -26,7 +25,7 @@ func (r *Request) GetBody() []byte {
 func (r *Request) Bind(v interface{}) error {
 	err := json.Unmarshal(r.GetBody(), v)
 	if err != nil {
-		log.Error().Err(err).Str("type", ErrPack).Str("name", "request").Str("method", "Bind").Str("body", string(r.GetBody())).Send()
+		log.Error().Err(err).Str("type", ErrPack).Str("name", "request").Str("method", "Bind").Str("body", log.MaskBody(r.GetBody())).Send()
 	}
 	return err
 }
@@ -128,15 +127,9 @@ func (r *Request) GetHeader(key string) string {
 	return r.Ctx.GetHeader(key)
 }
 
-//获取客户端IP
+//获取客户端IP，只信任 ServerConfig.TrustedProxies 中的代理设置的请求头
 func (r *Request) GetIP() string {
-	if r.Ctx.Request.Header.Get("X-Real-IP") != "" {
-		return strings.Split(r.Ctx.Request.Header.Get("X-Real-IP"), ",")[0]
-	}
-	if r.Ctx.Request.Header.Get("X-Forwarded-For") != "" {
-		return strings.Split(r.Ctx.Request.Header.Get("X-Forwarded-For"), ",")[0]
-	}
-	return r.Ctx.ClientIP()
+	return ClientIP(r.Ctx)
 }
 
 //获取user-agent
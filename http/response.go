@@ -1,10 +1,19 @@
 package http
 
 import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+
 	"github.com/Mueat/frm-lib/errors"
+	elog "github.com/Mueat/frm-lib/log"
+	"github.com/Mueat/frm-lib/trace"
 	"github.com/gin-gonic/gin"
 )
 
+// gin.Context中保存返回的错误码的key
+const respCodeCtxKey = "$resp_code"
+
 type Response struct {
 	Ctx        *gin.Context
 	StatusCode int
@@ -26,37 +35,98 @@ func (r *Response) Send(str string) {
 }
 
 func (r *Response) Json(v interface{}) {
+	if config.ETag && isConditionalMethod(r.Ctx.Request.Method) && (r.StatusCode == 0 || r.StatusCode == http.StatusOK) {
+		buf := &bytes.Buffer{}
+		enc := json.NewEncoder(buf)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(v); err == nil {
+			if r.ETag(makeETag(buf.Bytes())) {
+				return
+			}
+			r.Ctx.Data(http.StatusOK, "application/json; charset=utf-8", buf.Bytes())
+			return
+		}
+	}
 	r.Ctx.PureJSON(r.StatusCode, v)
 }
 
+// 渲染模板
+// obj 为 gin.H、map[string]interface{} 或 nil 时，会自动加入 csrfToken 和 cspNonce 供模板使用
 func (r *Response) HTML(code int, name string, obj interface{}) {
-	r.Ctx.HTML(code, name, obj)
+	r.Ctx.HTML(code, name, r.templateData(obj))
 }
 
-func (r *Response) Resp(v interface{}, err *errors.Err) {
+// 模板数据中加入请求相关的变量
+func (r *Response) templateData(obj interface{}) interface{} {
+	var data map[string]interface{}
+	switch v := obj.(type) {
+	case nil:
+		data = make(map[string]interface{})
+	case gin.H:
+		data = v
+	case map[string]interface{}:
+		data = v
+	default:
+		return obj
+	}
+	vars := map[string]string{
+		"csrfToken": r.Ctx.GetString(csrfCtxKey),
+		"cspNonce":  r.Ctx.GetString(cspNonceCtxKey),
+	}
+	res := make(gin.H, len(data)+len(vars))
+	for k, v := range vars {
+		if v != "" {
+			res[k] = v
+		}
+	}
+	for k, v := range data {
+		res[k] = v
+	}
+	return res
+}
+
+// 返回数据或者错误，错误只返回错误码和错误信息，被包装的错误和调用栈记录到日志中
+// err 可以是 *errors.Err 或者普通错误，普通错误根据 errors.Register 注册的对应关系转换错误码
+func (r *Response) Resp(v interface{}, e error) {
+	r.resp(v, e)
+}
+
+// 普通错误转换后的调用位置为调用 Resp 的方法
+func (r *Response) resp(v interface{}, e error) {
+	var err *errors.Err
+	if ee, ok := e.(*errors.Err); ok {
+		err = ee
+	} else {
+		err = errors.FromSkip(e, 2)
+	}
 	if err == nil || err.Code == errors.OK {
 		r.Success(v)
 	} else {
-		r.Error(err.Code, err.Msg)
+		if err.Cause() != nil {
+			elog.Warn().Err(err).Str("type", ErrPack).Str("name", "response").Str("method", "Resp").Str("request_id", trace.FromContext(r.Ctx.Request.Context())).Send()
+		}
+		if reporter != nil {
+			reporter.Capture(r.Ctx.Request.Context(), err, reportFields(r.Ctx))
+		}
+		r.Error(err.Code, r.localize(err.Code, err.Msg, err.Params))
 	}
 }
 
 func (r *Response) Success(v interface{}) {
-	apiResp := ApiResponse{
-		Code: 0,
-		Msg:  "success",
-		Data: v,
-	}
-	r.Json(apiResp)
+	r.Render(r.envelope(errors.OK, "success", v))
 }
 
+// 返回错误，通过 GinServer.SetEnvelope 配置后可以返回对应的HTTP状态码或者RFC 7807格式
 func (r *Response) Error(code int, msg string) {
-	apiResp := ApiResponse{
-		Code: code,
-		Msg:  msg,
-		Data: nil,
+	r.Ctx.Set(respCodeCtxKey, code)
+	if envelopeConf != nil && envelopeConf.ProblemJSON {
+		r.problem(code, msg)
+		return
+	}
+	if r.StatusCode == 0 {
+		r.StatusCode = errorStatus(code)
 	}
-	r.Json(apiResp)
+	r.Render(r.envelope(code, msg, nil))
 }
 
 // Abort
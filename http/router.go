@@ -1,11 +1,63 @@
 package http
 
+import (
+	"path"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
 type RouterFun func(app *App)
 
 type Router struct {
 	Method  string
 	URL     string
 	Handler RouterFun
+	// 访问该路由需要的权限，对应路由方法注释中的 @perm 注解
+	Perm string
+	// 处理超时时间，单位：毫秒，0表示使用 ServerConfig.RequestTimeout，负数表示不限制
+	Timeout int64
+	// 请求体最大字节数，0表示使用 ServerConfig.MaxBodySize，负数表示不限制
+	MaxBodySize int64
+	// 响应编码，如 EncodingXML，为空时根据 Accept 选择
+	Format string
+}
+
+// 路由的附加信息
+type routeMeta struct {
+	perm        string
+	timeout     int64
+	maxBodySize int64
+	format      string
+}
+
+// 路由的附加信息，key为 请求方法 + 空格 + 完整路由
+var (
+	routeMetas   = make(map[string]*routeMeta)
+	routeMetasMu sync.RWMutex
+)
+
+// 获取路由的附加信息，未设置时返回零值
+func getRouteMeta(method, fullPath string) routeMeta {
+	routeMetasMu.RLock()
+	defer routeMetasMu.RUnlock()
+	if meta, ok := routeMetas[method+" "+fullPath]; ok {
+		return *meta
+	}
+	return routeMeta{}
+}
+
+// 修改路由的附加信息，不存在时自动创建
+func setRouteMeta(method, fullPath string, fn func(meta *routeMeta)) {
+	routeMetasMu.Lock()
+	defer routeMetasMu.Unlock()
+	key := method + " " + fullPath
+	meta, ok := routeMetas[key]
+	if !ok {
+		meta = &routeMeta{}
+		routeMetas[key] = meta
+	}
+	fn(meta)
 }
 
 func MergeRouters(routers ...[]Router) []Router {
@@ -15,3 +67,64 @@ func MergeRouters(routers ...[]Router) []Router {
 	}
 	return rts
 }
+
+// 声明路由需要的权限
+// @param string method 请求方法
+// @param string fullPath 完整的路由地址，与gin中的FullPath一致
+// @param string perm 权限名称
+func SetRoutePerm(method, fullPath, perm string) {
+	if perm == "" {
+		return
+	}
+	setRouteMeta(method, fullPath, func(meta *routeMeta) {
+		meta.perm = perm
+	})
+}
+
+// 获取当前请求路由声明的权限，未声明则返回空字符串
+func GetRoutePerm(c *gin.Context) string {
+	return getRouteMeta(c.Request.Method, c.FullPath()).perm
+}
+
+// 设置路由的处理超时时间和请求体大小限制，0表示使用全局配置，负数表示不限制
+// @param string method 请求方法
+// @param string fullPath 完整的路由地址，与gin中的FullPath一致
+// @param int64 timeout 超时时间，单位：毫秒
+// @param int64 maxBodySize 请求体最大字节数
+func SetRouteLimit(method, fullPath string, timeout int64, maxBodySize int64) {
+	if timeout == 0 && maxBodySize == 0 {
+		return
+	}
+	setRouteMeta(method, fullPath, func(meta *routeMeta) {
+		meta.timeout = timeout
+		meta.maxBodySize = maxBodySize
+	})
+}
+
+// 获取当前请求的超时时间，单位：毫秒
+func getRouteTimeout(c *gin.Context) int64 {
+	if meta := getRouteMeta(c.Request.Method, c.FullPath()); meta.timeout != 0 {
+		return meta.timeout
+	}
+	return config.RequestTimeout
+}
+
+// 获取当前请求的请求体大小限制
+func getRouteMaxBodySize(c *gin.Context) int64 {
+	if meta := getRouteMeta(c.Request.Method, c.FullPath()); meta.maxBodySize != 0 {
+		return meta.maxBodySize
+	}
+	return config.MaxBodySize
+}
+
+// 拼接路由地址，与gin的拼接规则保持一致
+func joinPaths(absolutePath, relativePath string) string {
+	if relativePath == "" {
+		return absolutePath
+	}
+	finalPath := path.Join(absolutePath, relativePath)
+	if relativePath[len(relativePath)-1] == '/' && finalPath[len(finalPath)-1] != '/' {
+		return finalPath + "/"
+	}
+	return finalPath
+}
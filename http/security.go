@@ -0,0 +1,104 @@
+package http
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Mueat/frm-lib/util"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// gin.Context中保存csp nonce的key
+	cspNonceCtxKey = "$csp_nonce"
+	// CSP中的nonce占位符，如：script-src 'self' 'nonce-{nonce}'
+	CSPNoncePlaceholder = "{nonce}"
+)
+
+// 安全响应头配置
+type SecurityConfig struct {
+	HSTSMaxAge            int64  // Strict-Transport-Security 的 max-age，单位：秒，0表示不发送
+	HSTSIncludeSubdomains bool   // HSTS是否包含子域名
+	HSTSPreload           bool   // HSTS是否加入preload
+	CSP                   string // Content-Security-Policy，可以使用 {nonce} 占位符
+	CSPReportOnly         bool   // 是否只上报不拦截
+	FrameOptions          string // X-Frame-Options，DENY 或 SAMEORIGIN
+	ReferrerPolicy        string // Referrer-Policy
+	NoSniff               bool   // 是否发送 X-Content-Type-Options: nosniff
+}
+
+// 根据环境获取默认的安全响应头配置
+// 正式环境和预发环境开启HSTS，开发环境不限制内联脚本
+func DefaultSecurityConfig(env string) SecurityConfig {
+	conf := SecurityConfig{
+		CSP:            "default-src 'self'; script-src 'self' 'nonce-{nonce}'; style-src 'self' 'unsafe-inline'; img-src 'self' data:; object-src 'none'; base-uri 'self'; frame-ancestors 'self'",
+		FrameOptions:   "SAMEORIGIN",
+		ReferrerPolicy: "strict-origin-when-cross-origin",
+		NoSniff:        true,
+	}
+	switch env {
+	case PRODUCTION, UAT:
+		conf.HSTSMaxAge = 31536000
+		conf.HSTSIncludeSubdomains = true
+	case DEVELOPMENT:
+		conf.CSP = "default-src 'self' 'unsafe-inline' 'unsafe-eval' data: blob: ws:"
+	}
+	return conf
+}
+
+// 设置安全响应头
+// @param map[string]SecurityConfig confs 按照环境配置，key为 ServerConfig.Environment，找不到当前环境时使用 DefaultSecurityConfig
+func (s *GinServer) SetSecurityHeaders(confs map[string]SecurityConfig) {
+	conf, ok := confs[config.Environment]
+	if !ok {
+		conf = DefaultSecurityConfig(config.Environment)
+	}
+
+	hsts := ""
+	if conf.HSTSMaxAge > 0 {
+		hsts = fmt.Sprintf("max-age=%d", conf.HSTSMaxAge)
+		if conf.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if conf.HSTSPreload {
+			hsts += "; preload"
+		}
+	}
+	cspHeader := "Content-Security-Policy"
+	if conf.CSPReportOnly {
+		cspHeader = "Content-Security-Policy-Report-Only"
+	}
+	useNonce := strings.Contains(conf.CSP, CSPNoncePlaceholder)
+
+	s.Engine.Use(func(c *gin.Context) {
+		h := c.Writer.Header()
+		if hsts != "" {
+			h.Set("Strict-Transport-Security", hsts)
+		}
+		if conf.CSP != "" {
+			csp := conf.CSP
+			if useNonce {
+				nonce, err := util.GenerateNonce(24)
+				if err == nil {
+					c.Set(cspNonceCtxKey, nonce)
+					csp = strings.ReplaceAll(csp, CSPNoncePlaceholder, nonce)
+				}
+			}
+			h.Set(cspHeader, csp)
+		}
+		if conf.FrameOptions != "" {
+			h.Set("X-Frame-Options", conf.FrameOptions)
+		}
+		if conf.ReferrerPolicy != "" {
+			h.Set("Referrer-Policy", conf.ReferrerPolicy)
+		}
+		if conf.NoSniff {
+			h.Set("X-Content-Type-Options", "nosniff")
+		}
+	})
+}
+
+// 获取当前请求的csp nonce，在模板中用于 <script nonce="...">
+func (a *App) CSPNonce() string {
+	return a.GetString(cspNonceCtxKey)
+}
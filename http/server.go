@@ -2,6 +2,7 @@ package http
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"io/ioutil"
 	"log"
@@ -12,6 +13,7 @@ import (
 
 	"github.com/Mueat/frm-lib/errors"
 	elog "github.com/Mueat/frm-lib/log"
+	"github.com/Mueat/frm-lib/trace"
 	"github.com/Mueat/frm-lib/util"
 	"github.com/facebookgo/grace/gracehttp"
 	"github.com/gin-gonic/gin"
@@ -43,11 +45,24 @@ type ServerConfig struct {
 	TimeZone string
 	// 监听地址
 	ListenAddr string
+	// 跨域配置，配置了允许的来源时自动启用，配置无效时 Init 会panic
+	CORS CORSConfig
+	// 信任的代理，支持CIDR和单个IP，只有来自这些地址的请求才会读取代理请求头获取客户端IP，未配置时只信任本机
+	TrustedProxies []string
+	// 是否为GET请求的json响应生成ETag，客户端携带相同的 If-None-Match 时返回304
+	ETag bool
+	// 请求处理超时时间，单位：毫秒，0表示不限制
+	// 超时后会取消请求的context，通过 App.DB 和 App.Redis 执行的操作都会被取消
+	RequestTimeout int64
+	// 请求体最大字节数，0表示不限制，超过时返回413
+	MaxBodySize int64
 }
 
 // 服务
 type GinServer struct {
 	Engine *gin.Engine
+
+	session bool // 是否已经启用会话
 }
 
 var config ServerConfig
@@ -55,6 +70,7 @@ var config ServerConfig
 // 初始化
 func Init(conf ServerConfig) *GinServer {
 	config = conf
+	trustedProxies = parseTrustedProxies(conf.TrustedProxies)
 
 	var engine *gin.Engine
 	if IsDevelopment() {
@@ -68,16 +84,18 @@ func Init(conf ServerConfig) *GinServer {
 	engine.Use(func(c *gin.Context) {
 		defer func() {
 			if r := recover(); r != nil {
+				// 主动中断连接，交给net/http处理
+				if r == http.ErrAbortHandler {
+					panic(r)
+				}
 				//打印错误堆栈信息
 				log.Printf("panic: %v\n", r)
 				debug.PrintStack()
-				//封装通用json返回
-				apiResp := ApiResponse{
-					Code: errors.InternalServerError,
-					Msg:  errors.GetErrorMsg(errors.InternalServerError),
-					Data: nil,
+				if reporter != nil {
+					reporter.CapturePanic(c.Request.Context(), r, reportFields(c))
 				}
-				c.PureJSON(200, apiResp)
+				//封装通用返回
+				abortWithError(c, 0, errors.InternalServerError)
 			}
 		}()
 		//加载完 defer recover，继续后续接口调用
@@ -86,30 +104,50 @@ func Init(conf ServerConfig) *GinServer {
 
 	// 捕获404错误
 	engine.NoRoute(func(c *gin.Context) {
-		apiResp := ApiResponse{
-			Code: errors.NotFound,
-			Msg:  errors.GetErrorMsg(errors.NotFound),
-			Data: nil,
-		}
-		c.PureJSON(200, apiResp)
+		abortWithError(c, 0, errors.NotFound)
 	})
 
+	// 设置请求ID
+	engine.Use(setRequestID)
+
 	// 设置body
 	engine.Use(setBody)
 
+	// 设置超时
+	engine.Use(setTimeout)
+
 	ser := GinServer{
 		Engine: engine,
 	}
+
+	// 跨域
+	if len(conf.CORS.AllowOrigins) > 0 || len(conf.CORS.AllowOriginRegexps) > 0 {
+		if err := ser.SetCORS(conf.CORS); err != nil {
+			panic(err)
+		}
+	}
 	return &ser
 }
 
 // 设置body
 func setBody(c *gin.Context) {
+	limit := getRouteMaxBodySize(c)
+	if limit > 0 && c.Request.Body != nil {
+		if c.Request.ContentLength > limit {
+			abortTooLarge(c)
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+	}
 	if c.Request.Method == http.MethodPost || c.Request.Method == http.MethodPut || c.Request.Method == http.MethodDelete {
 		ct := c.ContentType()
 		if util.Stripos(ct, "application/json", 0) > -1 {
 			body, err := ioutil.ReadAll(c.Request.Body)
 			if err != nil {
+				c.Set("body", []byte{})
+				if limit > 0 && int64(len(body)) >= limit {
+					abortTooLarge(c)
+				}
 				return
 			}
 			_ = c.Request.Body.Close()
@@ -122,6 +160,39 @@ func setBody(c *gin.Context) {
 	c.Set("body", []byte{})
 }
 
+// 设置请求ID，优先使用上游传递的请求ID
+func setRequestID(c *gin.Context) {
+	id := c.GetHeader(trace.HeaderName)
+	if id == "" || len(id) > 64 {
+		id = trace.NewID()
+	}
+	c.Header(trace.HeaderName, id)
+	c.Request = c.Request.WithContext(trace.NewContext(c.Request.Context(), id))
+}
+
+// 请求体超过限制
+func abortTooLarge(c *gin.Context) {
+	c.Set("body", []byte{})
+	abortWithError(c, http.StatusRequestEntityTooLarge, errors.PayloadTooLarge)
+}
+
+// 设置超时
+// 超时后取消请求的context，处理方法需要自行检查context或者通过 App.DB 、App.Redis 访问数据
+func setTimeout(c *gin.Context) {
+	timeout := getRouteTimeout(c)
+	if timeout <= 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), time.Duration(timeout)*time.Millisecond)
+	defer cancel()
+	c.Request = c.Request.WithContext(ctx)
+	c.Next()
+
+	if ctx.Err() == context.DeadlineExceeded && !c.Writer.Written() {
+		abortWithError(c, http.StatusGatewayTimeout, errors.GatewayTimeout)
+	}
+}
+
 // 设置日志
 // @param string confName 日志名称
 func (s *GinServer) SetLogger(confName string) {
@@ -139,7 +210,8 @@ func (s *GinServer) SetLogger(confName string) {
 		bodyInter, _ := c.Get("body")
 		bodyBytes := bodyInter.([]byte)
 		mp := map[string]interface{}{
-			"$client_ip":            c.ClientIP(),
+			"$request_id":           trace.FromContext(c.Request.Context()),
+			"$client_ip":            ClientIP(c),
 			"$timestamp":            now.Format(time.RFC3339Nano),
 			"$timestamp_unix":       strconv.Itoa(int(now.UnixNano() / 1e6)),
 			"$server_addr":          util.GetServerIP(),
@@ -150,11 +222,11 @@ func (s *GinServer) SetLogger(confName string) {
 			"$http_user_agent":      c.Request.UserAgent(),
 			"$status":               c.Writer.Status(),
 			"$http_referer":         c.Request.Referer(),
-			"$request_uri":          c.Request.URL.String(),
-			"$args":                 c.Request.URL.RawQuery,
+			"$request_uri":          elog.MaskText(c.Request.URL.String()),
+			"$args":                 elog.MaskText(c.Request.URL.RawQuery),
 			"$http_x_forwarded_for": c.Request.Header.Get("X-Forwarded-For"),
 			"$error":                c.Errors.ByType(gin.ErrorTypePrivate).String(),
-			"$body":                 string(bodyBytes),
+			"$body":                 elog.MaskBody(bodyBytes),
 		}
 		conf := elog.GetConfig(confName)
 		logSeted := false
@@ -188,14 +260,20 @@ func (s *GinServer) Start() error {
 	)
 }
 
-// 绑定路由
-func (s *GinServer) Handle(method string, url string, handlers ...RouterFun) {
+// 添加接口前缀
+func apiURL(url string) string {
 	if config.ApiURLPrefix != "" {
 		url = config.ApiURLPrefix + url
 		if util.Substr(url, 0, 1) != "/" {
 			url = "/" + url
 		}
 	}
+	return url
+}
+
+// 绑定路由
+func (s *GinServer) Handle(method string, url string, handlers ...RouterFun) {
+	url = apiURL(url)
 	ginHandlers := make([]gin.HandlerFunc, 0)
 	for _, fun := range handlers {
 		f := fun
@@ -207,6 +285,16 @@ func (s *GinServer) Handle(method string, url string, handlers ...RouterFun) {
 	s.Engine.Handle(method, url, ginHandlers...)
 }
 
+// 声明路由需要的权限，url与Handle中的url一致
+func (s *GinServer) Perm(method string, url string, perm string) {
+	SetRoutePerm(method, joinPaths(s.Engine.BasePath(), apiURL(url)), perm)
+}
+
+// 设置路由的处理超时时间(毫秒)和请求体大小限制(字节)，url与Handle中的url一致
+func (s *GinServer) Limit(method string, url string, timeout int64, maxBodySize int64) {
+	SetRouteLimit(method, joinPaths(s.Engine.BasePath(), apiURL(url)), timeout, maxBodySize)
+}
+
 // 静态文件绑定
 func (s *GinServer) Static(url string, dir string) {
 	s.Engine.Static(url, dir)
@@ -250,6 +338,10 @@ func (s *GinServer) Group(groupURL string, routers []Router, middlewares ...Rout
 	{
 		for _, r := range routers {
 			handler := r.Handler
+			fullPath := joinPaths(gp.BasePath(), r.URL)
+			SetRoutePerm(r.Method, fullPath, r.Perm)
+			SetRouteLimit(r.Method, fullPath, r.Timeout, r.MaxBodySize)
+			SetRouteFormat(r.Method, fullPath, r.Format)
 			gp.Handle(r.Method, r.URL, func(c *gin.Context) {
 				app := InitApp(c)
 				handler(&app)
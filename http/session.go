@@ -0,0 +1,377 @@
+package http
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Mueat/frm-lib/cache"
+	elog "github.com/Mueat/frm-lib/log"
+	"github.com/Mueat/frm-lib/util"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// gin.Context中保存会话的key
+	sessionCtxKey = "$session"
+	// 会话中保存闪存消息的key
+	sessionFlashKey = "_flash"
+
+	DefaultSessionCookieName = "SESSIONID"
+	DefaultSessionMaxAge     = 7200
+	DefaultSessionPrefix     = "session:"
+)
+
+// 会话配置
+type SessionConfig struct {
+	CookieName string // cookie名称，默认：SESSIONID
+	Secret     string // cookie签名秘钥，必须设置
+	EncryptKey string // cookie加密秘钥，必须为32位，设置后cookie中的会话ID会被加密
+	MaxAge     int64  // 会话有效期，单位：秒，默认：7200秒，每次请求都会重新计算有效期
+	Path       string // cookie路径，默认：/
+	Domain     string // cookie域名
+	Secure     bool   // 是否只在https下发送cookie
+	HttpOnly   bool   // 是否禁止js读取cookie
+	SameSite   string // Lax Strict None
+	Prefix     string // 存储key的前缀，默认：session:
+}
+
+// 会话
+type Session struct {
+	id        string
+	data      map[string]interface{}
+	conf      *SessionConfig
+	store     cache.Store
+	ctx       *gin.Context
+	changed   bool
+	destroyed bool
+}
+
+// 启用会话，Secret 为空或者 EncryptKey 长度不是32位时panic
+// @param SessionConfig conf 会话配置
+// @param cache.Store store 会话存储，一般为 cache.GetRedis 返回的连接，测试时可以使用 cache.NewMemory()
+func (s *GinServer) SetSession(conf SessionConfig, store cache.Store) {
+	if conf.Secret == "" {
+		panic("http: session requires a secret")
+	}
+	if conf.EncryptKey != "" && len(conf.EncryptKey) != 32 {
+		panic("http: session encrypt key must be 32 bytes")
+	}
+	if conf.CookieName == "" {
+		conf.CookieName = DefaultSessionCookieName
+	}
+	if conf.MaxAge <= 0 {
+		conf.MaxAge = DefaultSessionMaxAge
+	}
+	if conf.Path == "" {
+		conf.Path = "/"
+	}
+	if conf.Prefix == "" {
+		conf.Prefix = DefaultSessionPrefix
+	}
+	s.session = true
+
+	s.Engine.Use(func(c *gin.Context) {
+		sess := &Session{
+			data:  make(map[string]interface{}),
+			conf:  &conf,
+			store: store,
+			ctx:   c,
+		}
+		if cookie, err := c.Cookie(conf.CookieName); err == nil && cookie != "" {
+			sess.load(cookie)
+		}
+		// 滑动过期，有效的会话每次请求都重新下发cookie
+		if sess.id != "" {
+			if err := sess.setCookie(); err != nil {
+				elog.Error().Err(err).Str("type", ErrPack).Str("name", "session").Str("method", "setCookie").Send()
+				c.AbortWithStatus(http.StatusInternalServerError)
+				return
+			}
+		}
+		c.Set(sessionCtxKey, sess)
+
+		c.Next()
+
+		if err := sess.Save(); err != nil {
+			elog.Error().Err(err).Str("type", ErrPack).Str("name", "session").Str("method", "Save").Send()
+		}
+	})
+}
+
+// 获取会话，未调用 GinServer.SetSession 时返回nil
+func (a *App) Session() *Session {
+	if v, ok := a.Request.Ctx.Get(sessionCtxKey); ok {
+		if sess, ok := v.(*Session); ok {
+			return sess
+		}
+	}
+	return nil
+}
+
+// 会话ID，新会话在写入数据之前为空
+func (s *Session) ID() string {
+	return s.id
+}
+
+// 获取值
+func (s *Session) Get(key string) interface{} {
+	return s.data[key]
+}
+
+// 获取string值
+func (s *Session) GetString(key string) string {
+	if v, ok := s.data[key].(string); ok {
+		return v
+	}
+	return ""
+}
+
+// 获取int64值
+func (s *Session) GetInt64(key string) int64 {
+	if v, ok := s.data[key].(float64); ok {
+		return int64(v)
+	}
+	return 0
+}
+
+// 获取bool值
+func (s *Session) GetBool(key string) bool {
+	if v, ok := s.data[key].(bool); ok {
+		return v
+	}
+	return false
+}
+
+// 设置值，值会被序列化为json保存
+// 新会话无法生成安全的会话ID时panic，由panic处理返回错误，不会使用可预测的会话ID
+func (s *Session) Set(key string, v interface{}) {
+	if err := s.ensureID(); err != nil {
+		panic(err)
+	}
+	s.data[key] = v
+	s.changed = true
+}
+
+// 删除值
+func (s *Session) Delete(key string) {
+	if _, ok := s.data[key]; ok {
+		delete(s.data, key)
+		s.changed = true
+	}
+}
+
+// 添加闪存消息，闪存消息在读取后自动删除
+func (s *Session) Flash(key string, v interface{}) {
+	flashes := s.flashes()
+	flashes[key] = append(flashes[key], v)
+	s.Set(sessionFlashKey, flashes)
+}
+
+// 读取并删除闪存消息
+func (s *Session) Flashes(key string) []interface{} {
+	flashes := s.flashes()
+	values, ok := flashes[key]
+	if !ok {
+		return nil
+	}
+	delete(flashes, key)
+	if len(flashes) == 0 {
+		s.Delete(sessionFlashKey)
+	} else {
+		s.Set(sessionFlashKey, flashes)
+	}
+	return values
+}
+
+// 重新生成会话ID，保留会话数据，登录成功后调用以防止会话固定攻击
+func (s *Session) Regenerate() error {
+	if s.id != "" {
+		if err := s.store.Del(s.key()); err != nil {
+			return err
+		}
+	}
+	s.id = ""
+	if err := s.ensureID(); err != nil {
+		return err
+	}
+	s.changed = true
+	return nil
+}
+
+// 销毁会话，同时删除cookie
+func (s *Session) Destroy() error {
+	if s.id != "" {
+		if err := s.store.Del(s.key()); err != nil {
+			return err
+		}
+	}
+	s.id = ""
+	s.data = make(map[string]interface{})
+	s.changed = false
+	s.destroyed = true
+	http.SetCookie(s.ctx.Writer, &http.Cookie{
+		Name:     s.conf.CookieName,
+		Value:    "",
+		Path:     s.conf.Path,
+		Domain:   s.conf.Domain,
+		MaxAge:   -1,
+		Secure:   s.conf.Secure,
+		HttpOnly: s.conf.HttpOnly,
+		SameSite: parseSameSite(s.conf.SameSite),
+	})
+	return nil
+}
+
+// 保存会话
+// 请求结束后会自动保存，在重定向等提前结束响应的场景下也可以手动调用
+func (s *Session) Save() error {
+	if s.destroyed || s.id == "" {
+		return nil
+	}
+	ex := time.Duration(s.conf.MaxAge) * time.Second
+	if !s.changed {
+		return s.store.Expire(s.key(), ex)
+	}
+	b, err := json.Marshal(s.data)
+	if err != nil {
+		return err
+	}
+	if err := s.store.Set(s.key(), string(b), ex); err != nil {
+		return err
+	}
+	s.changed = false
+	return nil
+}
+
+// 从cookie中加载会话
+func (s *Session) load(cookie string) {
+	id, ok := s.decodeID(cookie)
+	if !ok {
+		return
+	}
+	str := s.store.GetString(s.conf.Prefix + id)
+	if str == "" {
+		return
+	}
+	data := make(map[string]interface{})
+	if err := json.Unmarshal([]byte(str), &data); err != nil {
+		return
+	}
+	s.id = id
+	s.data = data
+}
+
+// 新会话生成ID并下发cookie
+func (s *Session) ensureID() error {
+	if s.id != "" {
+		return nil
+	}
+	id, err := util.GenerateNonce(32)
+	if err != nil {
+		return fmt.Errorf("http: generate session id: %w", err)
+	}
+	s.id = id
+	s.destroyed = false
+	return s.setCookie()
+}
+
+func (s *Session) setCookie() error {
+	value, err := s.encodeID(s.id)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(s.ctx.Writer, &http.Cookie{
+		Name:     s.conf.CookieName,
+		Value:    value,
+		Path:     s.conf.Path,
+		Domain:   s.conf.Domain,
+		MaxAge:   int(s.conf.MaxAge),
+		Expires:  time.Now().Add(time.Duration(s.conf.MaxAge) * time.Second),
+		Secure:   s.conf.Secure,
+		HttpOnly: s.conf.HttpOnly,
+		SameSite: parseSameSite(s.conf.SameSite),
+	})
+	return nil
+}
+
+// cookie中的值格式为 payload.签名，设置了加密秘钥时 payload 为 nonce:密文
+// 加密失败时返回错误，不会把明文的会话ID写入cookie
+func (s *Session) encodeID(id string) (string, error) {
+	payload := id
+	if s.conf.EncryptKey != "" {
+		nonce, err := util.GenerateNonce(12)
+		if err != nil {
+			return "", fmt.Errorf("http: encrypt session id: %w", err)
+		}
+		ciphertext, err := util.EncryptAES256GCM(s.conf.EncryptKey, s.conf.CookieName, nonce, id)
+		if err != nil {
+			return "", fmt.Errorf("http: encrypt session id: %w", err)
+		}
+		payload = nonce + ":" + util.Base64URLEncode(ciphertext)
+	}
+	return payload + "." + util.HMAC(sha256.New, payload, s.conf.Secret), nil
+}
+
+func (s *Session) decodeID(cookie string) (string, bool) {
+	pos := strings.LastIndex(cookie, ".")
+	if pos < 1 {
+		return "", false
+	}
+	payload := cookie[:pos]
+	if !hmac.Equal([]byte(util.HMAC(sha256.New, payload, s.conf.Secret)), []byte(cookie[pos+1:])) {
+		return "", false
+	}
+	if s.conf.EncryptKey == "" {
+		return payload, true
+	}
+	parts := strings.SplitN(payload, ":", 2)
+	if len(parts) != 2 {
+		return "", false
+	}
+	ciphertext, err := util.Base64URLDecode(parts[1])
+	if err != nil {
+		return "", false
+	}
+	id, err := util.DecryptAES256GCM(s.conf.EncryptKey, s.conf.CookieName, parts[0], ciphertext)
+	if err != nil {
+		return "", false
+	}
+	return id, true
+}
+
+func (s *Session) key() string {
+	return s.conf.Prefix + s.id
+}
+
+func (s *Session) flashes() map[string][]interface{} {
+	flashes := make(map[string][]interface{})
+	switch v := s.data[sessionFlashKey].(type) {
+	case map[string][]interface{}:
+		flashes = v
+	case map[string]interface{}:
+		for k, items := range v {
+			if arr, ok := items.([]interface{}); ok {
+				flashes[k] = arr
+			}
+		}
+	}
+	return flashes
+}
+
+func parseSameSite(sameSite string) http.SameSite {
+	switch strings.ToLower(sameSite) {
+	case "lax":
+		return http.SameSiteLaxMode
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteDefaultMode
+	}
+}
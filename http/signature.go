@@ -0,0 +1,114 @@
+package http
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"io/ioutil"
+	"strconv"
+	"time"
+
+	"github.com/Mueat/frm-lib/cache"
+	"github.com/Mueat/frm-lib/errors"
+	"github.com/Mueat/frm-lib/util"
+)
+
+const (
+	// gin.Context中保存调用方ID的key
+	signAppIDCtxKey = "$sign_app_id"
+
+	DefaultSignMaxSkew     = 300
+	DefaultSignNoncePrefix = "sign:nonce:"
+)
+
+// 请求签名配置
+type SignatureConfig struct {
+	Secrets     map[string]string                  // 调用方ID对应的秘钥
+	SecretFunc  func(appID string) (string, error) // 获取调用方秘钥的方法，设置后不再使用Secrets
+	MaxSkew     int64                              // 允许的时间误差，单位：秒，默认：300秒
+	NoncePrefix string                             // 随机字符串存储key的前缀，默认：sign:nonce:
+}
+
+// 请求签名校验中间件，用于服务间调用和开放接口
+// 签名方法见 util.SignRequest，调用方可以使用 curl.Opts 中的 SignAppID 和 SignSecret 自动签名
+// @param SignatureConfig conf 配置
+// @param cache.Store store 用于防重放的存储，一般为 cache.GetRedis 返回的连接
+func Signature(conf SignatureConfig, store cache.Store) RouterFun {
+	if conf.MaxSkew <= 0 {
+		conf.MaxSkew = DefaultSignMaxSkew
+	}
+	if conf.NoncePrefix == "" {
+		conf.NoncePrefix = DefaultSignNoncePrefix
+	}
+
+	return func(app *App) {
+		if err := conf.verify(app, store); err != nil {
+			app.Response.Error(err.Code, err.Msg)
+			app.Abort()
+			return
+		}
+	}
+}
+
+func (conf *SignatureConfig) verify(app *App, store cache.Store) *errors.Err {
+	c := app.GetContext()
+	appID := c.GetHeader(util.SIGN_HEADER_APPID)
+	timestamp := c.GetHeader(util.SIGN_HEADER_TIMESTAMP)
+	nonce := c.GetHeader(util.SIGN_HEADER_NONCE)
+	sign := c.GetHeader(util.SIGN_HEADER_SIGNATURE)
+	if appID == "" || timestamp == "" || nonce == "" || sign == "" {
+		return errors.CodeMsg(errors.Unauthorized, "signature headers required")
+	}
+
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.CodeMsg(errors.Unauthorized, "signature timestamp error")
+	}
+	if skew := time.Now().Unix() - ts; skew > conf.MaxSkew || skew < -conf.MaxSkew {
+		return errors.CodeMsg(errors.Unauthorized, "signature expired")
+	}
+
+	secret := ""
+	if conf.SecretFunc != nil {
+		secret, err = conf.SecretFunc(appID)
+		if err != nil {
+			return errors.CodeMsg(errors.Unauthorized, "signature app error")
+		}
+	} else {
+		secret = conf.Secrets[appID]
+	}
+	if secret == "" {
+		return errors.CodeMsg(errors.Unauthorized, "signature app error")
+	}
+
+	var body []byte
+	if v, ok := c.Get("body"); ok {
+		body, _ = v.([]byte)
+	}
+	if len(body) == 0 && c.Request.Body != nil {
+		if b, err := ioutil.ReadAll(c.Request.Body); err == nil {
+			body = b
+			c.Request.Body = ioutil.NopCloser(bytes.NewBuffer(b))
+		}
+	}
+	expected := util.SignRequest(secret, c.Request.Method, c.Request.URL.EscapedPath(), c.Request.URL.RawQuery, body, ts, nonce)
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(sign)) != 1 {
+		return errors.CodeMsg(errors.Unauthorized, "signature error")
+	}
+
+	// 签名正确后再记录随机字符串，避免伪造的请求占用
+	ok, err := store.SetNXEX(conf.NoncePrefix+appID+":"+nonce, timestamp, time.Duration(conf.MaxSkew*2)*time.Second)
+	if err != nil {
+		return errors.Code(errors.System)
+	}
+	if !ok {
+		return errors.CodeMsg(errors.Unauthorized, "signature nonce reused")
+	}
+
+	c.Set(signAppIDCtxKey, appID)
+	return nil
+}
+
+// 获取签名校验通过的调用方ID
+func (a *App) SignAppID() string {
+	return a.GetString(signAppIDCtxKey)
+}
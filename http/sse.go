@@ -0,0 +1,315 @@
+package http
+
+import (
+	"container/list"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+)
+
+var ErrSSEClosed = errors.New("sse: stream closed")
+
+// SSE事件
+type SSEEvent struct {
+	ID    string      // 事件ID，客户端重连时通过 Last-Event-ID 传回
+	Event string      // 事件名称，为空时客户端触发 message 事件
+	Data  interface{} // 事件数据，struct、map、slice会转为json
+	Retry int64       // 客户端重连间隔，单位：毫秒
+}
+
+// SSE重放缓冲区，用于客户端断线重连后补发错过的事件
+type SSEReplay interface {
+	// 保存事件，ID为空时生成ID，返回保存后的事件
+	Add(stream string, ev SSEEvent) (SSEEvent, error)
+	// 获取指定ID之后的事件
+	Since(stream string, lastID string) ([]SSEEvent, error)
+}
+
+// SSE配置
+type SSEConfig struct {
+	Heartbeat int64     // 心跳注释的发送间隔，单位：秒，默认：15秒，负数表示不发送
+	Retry     int64     // 建议客户端的重连间隔，单位：毫秒，0表示不设置
+	Replay    SSEReplay // 重放缓冲区，为空时不支持断线续传
+}
+
+// SSE事件流
+type SSEStream struct {
+	name    string
+	conf    SSEConfig
+	w       gin.ResponseWriter
+	ctxDone <-chan struct{}
+	mu      sync.Mutex
+	closed  bool
+	closeCh chan struct{}
+	done    chan struct{}
+}
+
+// 开始SSE响应，会补发 Last-Event-ID 之后的事件
+// 通过 GinServer.SSE 注册的路由不限制处理时间，其他路由需要通过 GinServer.Limit 或 Router.Timeout 设置为不超时
+//
+//	stream := app.SSE("order:1", http.SSEConfig{Replay: replay})
+//	defer stream.Close()
+//	for {
+//		select {
+//		case <-stream.Done():
+//			return
+//		case p := <-progress:
+//			stream.Send(http.SSEEvent{Event: "progress", Data: p})
+//		}
+//	}
+//
+// @param string name 事件流名称，用于重放缓冲区区分不同的事件流
+func (r *Response) SSE(name string, conf SSEConfig) *SSEStream {
+	if conf.Heartbeat == 0 {
+		conf.Heartbeat = 15
+	}
+	c := r.Ctx
+	h := c.Writer.Header()
+	h.Set("Content-Type", sse.ContentType)
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	// 关闭nginx缓冲
+	h.Set("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	s := &SSEStream{
+		name:    name,
+		conf:    conf,
+		w:       c.Writer,
+		ctxDone: c.Request.Context().Done(),
+		closeCh: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go func() {
+		select {
+		case <-s.ctxDone:
+		case <-s.closeCh:
+		}
+		close(s.done)
+	}()
+	if conf.Retry > 0 {
+		s.write("retry:" + strconv.FormatInt(conf.Retry, 10) + "\n\n")
+	} else {
+		s.write(":ok\n\n")
+	}
+
+	lastID := c.GetHeader("Last-Event-ID")
+	if lastID == "" {
+		lastID = c.Query("lastEventId")
+	}
+	if conf.Replay != nil && lastID != "" {
+		events, err := conf.Replay.Since(name, lastID)
+		if err == nil {
+			for _, ev := range events {
+				if s.encode(ev) != nil {
+					break
+				}
+			}
+		}
+	}
+
+	if conf.Heartbeat > 0 {
+		go s.heartbeat()
+	}
+	return s
+}
+
+// 注册SSE路由，长连接不限制处理时间
+//
+//	s.SSE("/orders/:id/events", func(app *http.App) {
+//		stream := app.SSE("order:"+app.GetParam("id"), http.SSEConfig{Replay: replay})
+//		defer stream.Close()
+//		...
+//	}, authMiddleware)
+//
+// @param string url 路由地址
+// @param RouterFun handler 处理方法，调用 App.SSE 开始事件流
+// @param ...RouterFun middlewares 中间件，如鉴权
+func (s *GinServer) SSE(url string, handler RouterFun, middlewares ...RouterFun) {
+	SetRouteLimit(http.MethodGet, joinPaths(s.Engine.BasePath(), apiURL(url)), -1, 0)
+	handlers := make([]RouterFun, 0, len(middlewares)+1)
+	handlers = append(handlers, middlewares...)
+	s.Handle(http.MethodGet, url, append(handlers, handler)...)
+}
+
+// 开始SSE响应
+func (a *App) SSE(name string, conf SSEConfig) *SSEStream {
+	return a.Response.SSE(name, conf)
+}
+
+// 客户端断开连接或者调用Close后返回的channel会被关闭
+func (s *SSEStream) Done() <-chan struct{} {
+	return s.done
+}
+
+// 发送事件，配置了重放缓冲区时先保存再发送，ID为空时由重放缓冲区生成，否则使用传入的ID
+func (s *SSEStream) Send(ev SSEEvent) error {
+	if s.conf.Replay != nil {
+		saved, err := s.conf.Replay.Add(s.name, ev)
+		if err != nil {
+			return err
+		}
+		ev = saved
+	}
+	return s.encode(ev)
+}
+
+// 发送注释，客户端会忽略
+func (s *SSEStream) Comment(text string) error {
+	return s.write(":" + text + "\n\n")
+}
+
+// 结束事件流，处理方法返回前必须调用
+func (s *SSEStream) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.closeCh)
+	}
+}
+
+func (s *SSEStream) encode(ev SSEEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrSSEClosed
+	}
+	retry := uint(0)
+	if ev.Retry > 0 {
+		retry = uint(ev.Retry)
+	}
+	err := sse.Encode(s.w, sse.Event{Id: ev.ID, Event: ev.Event, Retry: retry, Data: ev.Data})
+	return s.flush(err)
+}
+
+func (s *SSEStream) write(str string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrSSEClosed
+	}
+	_, err := s.w.WriteString(str)
+	return s.flush(err)
+}
+
+// 输出缓冲的内容，写入失败说明客户端已经断开
+func (s *SSEStream) flush(err error) error {
+	if err == nil {
+		s.w.Flush()
+		select {
+		case <-s.ctxDone:
+			err = ErrSSEClosed
+		default:
+			return nil
+		}
+	}
+	s.closed = true
+	close(s.closeCh)
+	return err
+}
+
+func (s *SSEStream) heartbeat() {
+	ticker := time.NewTicker(time.Duration(s.conf.Heartbeat) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if s.write(":\n\n") != nil {
+				return
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// 内存重放缓冲区默认最多保存的事件流数量
+const DefaultSSEMaxStreams = 10000
+
+// 内存重放缓冲区，每个事件流保存最近的事件
+// 事件流数量超过限制时淘汰最久没有使用的事件流
+type SSEMemoryReplay struct {
+	size       int
+	maxStreams int
+	mu         sync.Mutex
+	seq        int64
+	streams    map[string]*list.Element
+	lru        *list.List // 最近使用的事件流在前面
+}
+
+type sseReplayStream struct {
+	name   string
+	events []SSEEvent
+}
+
+// 创建内存重放缓冲区
+// @param int size 每个事件流保存的事件数量，默认：100
+// @param int maxStreams 最多保存的事件流数量，默认：10000
+func NewSSEMemoryReplay(size int, maxStreams int) *SSEMemoryReplay {
+	if size <= 0 {
+		size = 100
+	}
+	if maxStreams <= 0 {
+		maxStreams = DefaultSSEMaxStreams
+	}
+	return &SSEMemoryReplay{size: size, maxStreams: maxStreams, streams: make(map[string]*list.Element), lru: list.New()}
+}
+
+// 获取事件流并标记为最近使用，需要持有锁
+func (m *SSEMemoryReplay) stream(name string, create bool) *sseReplayStream {
+	if e, ok := m.streams[name]; ok {
+		m.lru.MoveToFront(e)
+		return e.Value.(*sseReplayStream)
+	}
+	if !create {
+		return nil
+	}
+	st := &sseReplayStream{name: name}
+	m.streams[name] = m.lru.PushFront(st)
+	for m.lru.Len() > m.maxStreams {
+		oldest := m.lru.Back()
+		m.lru.Remove(oldest)
+		delete(m.streams, oldest.Value.(*sseReplayStream).name)
+	}
+	return st
+}
+
+// 保存事件，ID为空时使用自增ID
+func (m *SSEMemoryReplay) Add(stream string, ev SSEEvent) (SSEEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if ev.ID == "" {
+		m.seq++
+		ev.ID = strconv.FormatInt(m.seq, 10)
+	}
+	st := m.stream(stream, true)
+	events := append(st.events, ev)
+	if len(events) > m.size {
+		events = append([]SSEEvent(nil), events[len(events)-m.size:]...)
+	}
+	st.events = events
+	return ev, nil
+}
+
+// 获取指定ID之后的事件，ID不在缓冲区中时返回全部事件
+func (m *SSEMemoryReplay) Since(stream string, lastID string) ([]SSEEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	st := m.stream(stream, false)
+	if st == nil {
+		return nil, nil
+	}
+	events := st.events
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].ID == lastID {
+			return append([]SSEEvent(nil), events[i+1:]...), nil
+		}
+	}
+	return append([]SSEEvent(nil), events...), nil
+}
@@ -0,0 +1,553 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	elog "github.com/Mueat/frm-lib/log"
+	"github.com/Mueat/frm-lib/trace"
+)
+
+// websocket消息类型
+const (
+	WSTextMessage   = 1
+	WSBinaryMessage = 2
+	WSCloseMessage  = 8
+	WSPingMessage   = 9
+	WSPongMessage   = 10
+
+	wsContinuation = 0
+)
+
+// websocket关闭码
+const (
+	WSCloseNormal          = 1000
+	WSCloseGoingAway       = 1001
+	WSCloseProtocolError   = 1002
+	WSCloseInvalidPayload  = 1007
+	WSClosePolicyViolation = 1008
+	WSCloseMessageTooBig   = 1009
+	WSCloseInternalError   = 1011
+)
+
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+var (
+	ErrWSClosed     = errors.New("websocket: connection closed")
+	ErrWSBufferFull = errors.New("websocket: send buffer full")
+)
+
+// 客户端发送的关闭帧
+type WSCloseError struct {
+	Code int
+	Text string
+}
+
+func (e *WSCloseError) Error() string {
+	return "websocket: close " + strconv.Itoa(e.Code) + " " + e.Text
+}
+
+// websocket配置
+type WebSocketConfig struct {
+	Origins        []string            // 允许的来源，支持 * 和通配符，为空时只允许与Host相同的来源
+	Subprotocols   []string            // 支持的子协议，按顺序选择客户端请求中的第一个
+	SendBuffer     int                 // 每个连接的发送缓冲区消息数，默认：256，缓冲区满时断开连接
+	MaxMessageSize int64               // 接收消息的最大字节数，默认：1MB
+	PingInterval   int64               // 发送ping的间隔，单位：秒，默认：30秒
+	PongWait       int64               // 等待客户端消息（包括pong）的超时时间，单位：秒，默认：60秒
+	WriteTimeout   int64               // 写超时时间，单位：秒，默认：10秒
+	Hub            *Hub                // 连接所属的hub，用于房间和广播
+	CheckOrigin    func(app *App) bool // 自定义来源检查，设置后忽略 Origins
+}
+
+// websocket处理方法，方法返回后连接关闭
+type WSHandler func(conn *WSConn)
+
+type wsMessage struct {
+	op   int
+	data []byte
+}
+
+// websocket连接
+// 内嵌升级请求的App，可以读取中间件设置的用户信息，如 conn.GetContext().GetInt64("uid")
+type WSConn struct {
+	*App
+	ID string
+
+	conf      *WebSocketConfig
+	conn      net.Conn
+	br        *bufio.Reader
+	send      chan wsMessage
+	writeMu   sync.Mutex
+	closed    chan struct{}
+	pumpDone  chan struct{} // writePump 退出后关闭
+	closeOnce sync.Once
+	ctx       context.Context
+	cancel    context.CancelFunc
+	clientIP  string
+}
+
+// 注册websocket路由，中间件在升级之前执行，可以使用与普通接口相同的鉴权中间件
+// 中间件调用 Abort 后不会升级连接
+//
+//	server.WebSocket("/ws/order", http.WebSocketConfig{Hub: hub}, func(conn *http.WSConn) {
+//		conn.Join("order:" + orderID)
+//		for {
+//			if _, _, err := conn.ReadMessage(); err != nil {
+//				return
+//			}
+//		}
+//	}, authMiddleware)
+func (s *GinServer) WebSocket(url string, conf WebSocketConfig, handler WSHandler, middlewares ...RouterFun) {
+	if conf.SendBuffer <= 0 {
+		conf.SendBuffer = 256
+	}
+	if conf.MaxMessageSize <= 0 {
+		conf.MaxMessageSize = 1 << 20
+	}
+	if conf.PingInterval <= 0 {
+		conf.PingInterval = 30
+	}
+	if conf.PongWait <= 0 {
+		conf.PongWait = 60
+	}
+	if conf.PongWait <= conf.PingInterval {
+		conf.PongWait = conf.PingInterval * 2
+	}
+	if conf.WriteTimeout <= 0 {
+		conf.WriteTimeout = 10
+	}
+	if conf.CheckOrigin == nil {
+		conf.CheckOrigin = wsOriginChecker(conf.Origins)
+	}
+
+	// 长连接不限制处理时间
+	SetRouteLimit(http.MethodGet, joinPaths(s.Engine.BasePath(), apiURL(url)), -1, 0)
+
+	handlers := append(middlewares, func(app *App) {
+		conn, err := upgrade(app, &conf)
+		if err != nil {
+			elog.Error().Err(err).Str("type", ErrPack).Str("name", "websocket").Str("method", "upgrade").Str("request_id", app.RequestID()).Send()
+			return
+		}
+		defer conn.Close()
+		elog.Info().Str("type", ErrPack).Str("name", "websocket").Str("id", conn.ID).Str("request_id", app.RequestID()).Str("client_ip", conn.clientIP).Msg("connected")
+		defer func() {
+			elog.Info().Str("type", ErrPack).Str("name", "websocket").Str("id", conn.ID).Str("request_id", app.RequestID()).Str("client_ip", conn.clientIP).Msg("disconnected")
+		}()
+		handler(conn)
+	})
+	s.Handle(http.MethodGet, url, handlers...)
+}
+
+// 默认的来源检查
+func wsOriginChecker(origins []string) func(app *App) bool {
+	// 不允许携带cookie并且没有正则，不会返回错误
+	cs, _ := newCORS(CORSConfig{AllowOrigins: origins})
+	return func(app *App) bool {
+		c := app.GetContext()
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			return true
+		}
+		if len(origins) > 0 {
+			return cs.allowOrigin(origin)
+		}
+		pos := strings.Index(origin, "://")
+		return pos > -1 && strings.EqualFold(origin[pos+3:], c.Request.Host)
+	}
+}
+
+// 判断请求头中是否包含指定的值
+func headerContains(h http.Header, name string, value string) bool {
+	for _, v := range h.Values(name) {
+		for _, s := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(s), value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// 升级为websocket连接
+func upgrade(app *App, conf *WebSocketConfig) (*WSConn, error) {
+	c := app.GetContext()
+	r := c.Request
+	if r.Method != http.MethodGet ||
+		!headerContains(r.Header, "Connection", "upgrade") ||
+		!headerContains(r.Header, "Upgrade", "websocket") {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return nil, errors.New("websocket: not a websocket handshake")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		c.Header("Sec-WebSocket-Version", "13")
+		c.AbortWithStatus(http.StatusUpgradeRequired)
+		return nil, errors.New("websocket: unsupported version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return nil, errors.New("websocket: invalid Sec-WebSocket-Key")
+	}
+	if !conf.CheckOrigin(app) {
+		c.AbortWithStatus(http.StatusForbidden)
+		return nil, errors.New("websocket: origin not allowed: " + r.Header.Get("Origin"))
+	}
+
+	protocol := ""
+	for _, p := range conf.Subprotocols {
+		if headerContains(r.Header, "Sec-WebSocket-Protocol", p) {
+			protocol = p
+			break
+		}
+	}
+
+	// 访问日志记录101状态码
+	c.Writer.WriteHeader(http.StatusSwitchingProtocols)
+	netConn, rw, err := c.Writer.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha1.Sum([]byte(key + wsGUID))
+	resp := "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: " +
+		base64.StdEncoding.EncodeToString(sum[:]) + "\r\n"
+	if protocol != "" {
+		resp += "Sec-WebSocket-Protocol: " + protocol + "\r\n"
+	}
+	if id := app.RequestID(); id != "" {
+		resp += trace.HeaderName + ": " + id + "\r\n"
+	}
+	resp += "\r\n"
+	_ = netConn.SetWriteDeadline(time.Now().Add(time.Duration(conf.WriteTimeout) * time.Second))
+	if _, err := netConn.Write([]byte(resp)); err != nil {
+		_ = netConn.Close()
+		return nil, err
+	}
+	_ = netConn.SetWriteDeadline(time.Time{})
+
+	ctx, cancel := context.WithCancel(app.Context())
+	conn := &WSConn{
+		App:      app,
+		ID:       trace.NewID(),
+		conf:     conf,
+		conn:     netConn,
+		br:       rw.Reader,
+		send:     make(chan wsMessage, conf.SendBuffer),
+		closed:   make(chan struct{}),
+		pumpDone: make(chan struct{}),
+		ctx:      ctx,
+		cancel:   cancel,
+		clientIP: ClientIP(c),
+	}
+	if conf.Hub != nil {
+		conf.Hub.add(conn)
+	}
+	go conn.writePump()
+	return conn, nil
+}
+
+// 获取连接的context，连接关闭后会被取消
+func (w *WSConn) Context() context.Context {
+	return w.ctx
+}
+
+// 获取客户端IP
+func (w *WSConn) ClientIP() string {
+	return w.clientIP
+}
+
+// 连接关闭后返回的channel会被关闭
+func (w *WSConn) Done() <-chan struct{} {
+	return w.closed
+}
+
+// 读取一条消息，自动处理ping、pong和分片，收到关闭帧时返回 *WSCloseError
+// 同一个连接只能在一个goroutine中读取
+func (w *WSConn) ReadMessage() (int, []byte, error) {
+	msgType := 0
+	var msg []byte
+	for {
+		_ = w.conn.SetReadDeadline(time.Now().Add(time.Duration(w.conf.PongWait) * time.Second))
+		fin, op, payload, err := w.readFrame()
+		if err != nil {
+			if ce, ok := err.(*WSCloseError); ok {
+				w.closeWith(ce.Code, ce.Text)
+			} else {
+				w.Close()
+			}
+			return 0, nil, err
+		}
+
+		switch op {
+		case WSPingMessage:
+			if err := w.writeFrame(WSPongMessage, payload); err != nil {
+				w.Close()
+				return 0, nil, err
+			}
+			continue
+		case WSPongMessage:
+			continue
+		case WSCloseMessage:
+			ce := &WSCloseError{Code: WSCloseNormal}
+			if len(payload) >= 2 {
+				ce.Code = int(binary.BigEndian.Uint16(payload))
+				ce.Text = string(payload[2:])
+			}
+			w.closeWith(ce.Code, "")
+			return 0, nil, ce
+		case WSTextMessage, WSBinaryMessage:
+			if msgType != 0 {
+				err := &WSCloseError{Code: WSCloseProtocolError, Text: "unexpected data frame"}
+				w.closeWith(err.Code, err.Text)
+				return 0, nil, err
+			}
+			msgType = op
+			msg = payload
+		case wsContinuation:
+			if msgType == 0 {
+				err := &WSCloseError{Code: WSCloseProtocolError, Text: "unexpected continuation frame"}
+				w.closeWith(err.Code, err.Text)
+				return 0, nil, err
+			}
+			msg = append(msg, payload...)
+		}
+
+		if int64(len(msg)) > w.conf.MaxMessageSize {
+			err := &WSCloseError{Code: WSCloseMessageTooBig, Text: "message too big"}
+			w.closeWith(err.Code, err.Text)
+			return 0, nil, err
+		}
+		if fin {
+			if msgType == WSTextMessage && !utf8.Valid(msg) {
+				err := &WSCloseError{Code: WSCloseInvalidPayload, Text: "invalid utf8"}
+				w.closeWith(err.Code, err.Text)
+				return 0, nil, err
+			}
+			return msgType, msg, nil
+		}
+	}
+}
+
+// 读取json消息
+func (w *WSConn) ReadJSON(v interface{}) error {
+	_, msg, err := w.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(msg, v)
+}
+
+// 读取一个帧，协议错误时返回 *WSCloseError
+func (w *WSConn) readFrame() (bool, int, []byte, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(w.br, head[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin := head[0]&0x80 != 0
+	op := int(head[0] & 0x0f)
+	if head[0]&0x70 != 0 {
+		return false, 0, nil, &WSCloseError{Code: WSCloseProtocolError, Text: "reserved bits set"}
+	}
+	switch op {
+	case wsContinuation, WSTextMessage, WSBinaryMessage:
+	case WSCloseMessage, WSPingMessage, WSPongMessage:
+		if !fin || head[1]&0x7f > 125 {
+			return false, 0, nil, &WSCloseError{Code: WSCloseProtocolError, Text: "invalid control frame"}
+		}
+	default:
+		return false, 0, nil, &WSCloseError{Code: WSCloseProtocolError, Text: "unknown opcode"}
+	}
+	// 客户端发送的帧必须掩码
+	if head[1]&0x80 == 0 {
+		return false, 0, nil, &WSCloseError{Code: WSCloseProtocolError, Text: "frame not masked"}
+	}
+
+	length := uint64(head[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(w.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(w.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if length > uint64(w.conf.MaxMessageSize) {
+		return false, 0, nil, &WSCloseError{Code: WSCloseMessageTooBig, Text: "message too big"}
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(w.br, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(w.br, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, op, payload, nil
+}
+
+// 写入一个帧，服务端发送的帧不掩码
+func (w *WSConn) writeFrame(op int, data []byte) error {
+	frame := make([]byte, 0, len(data)+10)
+	frame = append(frame, 0x80|byte(op))
+	switch {
+	case len(data) <= 125:
+		frame = append(frame, byte(len(data)))
+	case len(data) <= 0xffff:
+		frame = append(frame, 126, byte(len(data)>>8), byte(len(data)))
+	default:
+		frame = append(frame, 127)
+		var ext [8]byte
+		binary.BigEndian.PutUint64(ext[:], uint64(len(data)))
+		frame = append(frame, ext[:]...)
+	}
+	frame = append(frame, data...)
+
+	w.writeMu.Lock()
+	defer w.writeMu.Unlock()
+	_ = w.conn.SetWriteDeadline(time.Now().Add(time.Duration(w.conf.WriteTimeout) * time.Second))
+	_, err := w.conn.Write(frame)
+	return err
+}
+
+// 发送消息和ping
+func (w *WSConn) writePump() {
+	err := w.pump()
+	close(w.pumpDone)
+	if err != nil {
+		w.Close()
+	}
+}
+
+// 连接关闭时返回nil，写入失败时返回错误
+func (w *WSConn) pump() error {
+	ticker := time.NewTicker(time.Duration(w.conf.PingInterval) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case m := <-w.send:
+			if err := w.writeFrame(m.op, m.data); err != nil {
+				return err
+			}
+		case <-ticker.C:
+			if err := w.writeFrame(WSPingMessage, nil); err != nil {
+				return err
+			}
+		case <-w.closed:
+			return nil
+		}
+	}
+}
+
+// 发送消息，消息放入发送缓冲区后立即返回
+// 缓冲区满说明客户端接收过慢，会断开连接并返回 ErrWSBufferFull
+func (w *WSConn) Send(msgType int, data []byte) error {
+	select {
+	case <-w.closed:
+		return ErrWSClosed
+	default:
+	}
+	select {
+	case w.send <- wsMessage{op: msgType, data: data}:
+		return nil
+	case <-w.closed:
+		return ErrWSClosed
+	default:
+		elog.Warn().Str("type", ErrPack).Str("name", "websocket").Str("id", w.ID).Str("client_ip", w.clientIP).Msg("send buffer full, closing connection")
+		// 在后台关闭，关闭时需要等待正在写入的帧，不能阻塞调用方，如房间广播
+		go w.closeWith(WSClosePolicyViolation, "send buffer full")
+		return ErrWSBufferFull
+	}
+}
+
+// 发送文本消息
+func (w *WSConn) SendText(text string) error {
+	return w.Send(WSTextMessage, []byte(text))
+}
+
+// 发送json消息
+func (w *WSConn) SendJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return w.Send(WSTextMessage, data)
+}
+
+// 加入房间
+func (w *WSConn) Join(rooms ...string) {
+	if w.conf.Hub != nil {
+		w.conf.Hub.Join(w, rooms...)
+	}
+}
+
+// 离开房间
+func (w *WSConn) Leave(rooms ...string) {
+	if w.conf.Hub != nil {
+		w.conf.Hub.Leave(w, rooms...)
+	}
+}
+
+// 关闭连接，发送缓冲区中的消息会在关闭帧之前发送
+func (w *WSConn) Close() {
+	w.closeWith(WSCloseNormal, "")
+}
+
+// 发送关闭帧并关闭连接
+func (w *WSConn) closeWith(code int, text string) {
+	w.closeOnce.Do(func() {
+		close(w.closed)
+		w.cancel()
+		if w.conf.Hub != nil {
+			w.conf.Hub.remove(w)
+		}
+		// 等待writePump退出后再写入，保证关闭帧是最后一个帧
+		<-w.pumpDone
+		if code == WSCloseNormal {
+			w.flush()
+		}
+		payload := make([]byte, 2, 2+len(text))
+		binary.BigEndian.PutUint16(payload, uint16(code))
+		payload = append(payload, text...)
+		_ = w.writeFrame(WSCloseMessage, payload)
+		_ = w.conn.Close()
+	})
+}
+
+// 发送缓冲区中剩余的消息
+func (w *WSConn) flush() {
+	for {
+		select {
+		case m := <-w.send:
+			if err := w.writeFrame(m.op, m.data); err != nil {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,380 @@
+package http
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 测试用的websocket客户端
+type wsTestClient struct {
+	t    *testing.T
+	conn net.Conn
+	br   *bufio.Reader
+}
+
+// 启动回显服务，返回服务地址
+func newWSTestServer(t *testing.T, conf WebSocketConfig, handler WSHandler) *httptest.Server {
+	gin.SetMode(gin.TestMode)
+	s := &GinServer{Engine: gin.New()}
+	if handler == nil {
+		handler = func(conn *WSConn) {
+			for {
+				op, msg, err := conn.ReadMessage()
+				if err != nil {
+					return
+				}
+				if err := conn.Send(op, msg); err != nil {
+					return
+				}
+			}
+		}
+	}
+	s.WebSocket("/ws", conf, handler)
+	srv := httptest.NewServer(s.Engine)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func dialWS(t *testing.T, srv *httptest.Server) *wsTestClient {
+	t.Helper()
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	req := "GET /ws HTTP/1.1\r\nHost: " + srv.Listener.Addr().String() + "\r\n" +
+		"Connection: Upgrade\r\nUpgrade: websocket\r\nSec-WebSocket-Version: 13\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n"
+	if _, err := conn.Write([]byte(req)); err != nil {
+		t.Fatal(err)
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("handshake status %d", resp.StatusCode)
+	}
+	// RFC 6455 中示例key对应的accept
+	if accept := resp.Header.Get("Sec-WebSocket-Accept"); accept != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("unexpected Sec-WebSocket-Accept %q", accept)
+	}
+	return &wsTestClient{t: t, conn: conn, br: br}
+}
+
+// 发送帧，客户端的帧默认掩码
+func (c *wsTestClient) writeFrame(fin bool, op int, payload []byte, masked bool) {
+	c.t.Helper()
+	b0 := byte(op)
+	if fin {
+		b0 |= 0x80
+	}
+	frame := []byte{b0}
+	maskBit := byte(0)
+	if masked {
+		maskBit = 0x80
+	}
+	switch {
+	case len(payload) <= 125:
+		frame = append(frame, maskBit|byte(len(payload)))
+	case len(payload) <= 0xffff:
+		frame = append(frame, maskBit|126, byte(len(payload)>>8), byte(len(payload)))
+	default:
+		var ext [8]byte
+		binary.BigEndian.PutUint64(ext[:], uint64(len(payload)))
+		frame = append(frame, maskBit|127)
+		frame = append(frame, ext[:]...)
+	}
+	data := append([]byte(nil), payload...)
+	if masked {
+		mask := [4]byte{0x12, 0x34, 0x56, 0x78}
+		frame = append(frame, mask[:]...)
+		for i := range data {
+			data[i] ^= mask[i%4]
+		}
+	}
+	frame = append(frame, data...)
+	if _, err := c.conn.Write(frame); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// 读取服务端的帧，服务端的帧不掩码，跳过ping
+func (c *wsTestClient) readFrame() (int, []byte) {
+	c.t.Helper()
+	for {
+		_ = c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		var head [2]byte
+		if _, err := io.ReadFull(c.br, head[:]); err != nil {
+			c.t.Fatal(err)
+		}
+		if head[0]&0x80 == 0 {
+			c.t.Fatal("server frame should not be fragmented")
+		}
+		if head[1]&0x80 != 0 {
+			c.t.Fatal("server frame must not be masked")
+		}
+		length := uint64(head[1] & 0x7f)
+		switch length {
+		case 126:
+			var ext [2]byte
+			io.ReadFull(c.br, ext[:])
+			length = uint64(binary.BigEndian.Uint16(ext[:]))
+		case 127:
+			var ext [8]byte
+			io.ReadFull(c.br, ext[:])
+			length = binary.BigEndian.Uint64(ext[:])
+		}
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(c.br, payload); err != nil {
+			c.t.Fatal(err)
+		}
+		op := int(head[0] & 0x0f)
+		if op == WSPingMessage {
+			continue
+		}
+		return op, payload
+	}
+}
+
+// 读取关闭帧并返回关闭码
+func (c *wsTestClient) expectClose(code int) {
+	c.t.Helper()
+	op, payload := c.readFrame()
+	if op != WSCloseMessage {
+		c.t.Fatalf("expected close frame, got op %d %q", op, payload)
+	}
+	if len(payload) < 2 {
+		c.t.Fatalf("close frame without code")
+	}
+	if got := int(binary.BigEndian.Uint16(payload)); got != code {
+		c.t.Fatalf("expected close code %d, got %d (%s)", code, got, payload[2:])
+	}
+	// 服务端发送关闭帧后关闭连接
+	_ = c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := c.br.ReadByte(); err != io.EOF {
+		c.t.Fatalf("expected connection closed, got %v", err)
+	}
+}
+
+func closePayload(code int, text string) []byte {
+	payload := make([]byte, 2, 2+len(text))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	return append(payload, text...)
+}
+
+func TestWebSocketEcho(t *testing.T) {
+	c := dialWS(t, newWSTestServer(t, WebSocketConfig{}, nil))
+	c.writeFrame(true, WSTextMessage, []byte("hello"), true)
+	if op, msg := c.readFrame(); op != WSTextMessage || string(msg) != "hello" {
+		t.Fatalf("unexpected echo %d %q", op, msg)
+	}
+	big := []byte(strings.Repeat("a", 70000))
+	c.writeFrame(true, WSBinaryMessage, big, true)
+	if op, msg := c.readFrame(); op != WSBinaryMessage || len(msg) != len(big) {
+		t.Fatalf("unexpected echo %d %d", op, len(msg))
+	}
+}
+
+func TestWebSocketFragmented(t *testing.T) {
+	c := dialWS(t, newWSTestServer(t, WebSocketConfig{}, nil))
+	c.writeFrame(false, WSTextMessage, []byte("Hel"), true)
+	c.writeFrame(false, wsContinuation, []byte("lo, "), true)
+	c.writeFrame(true, wsContinuation, []byte("world"), true)
+	if op, msg := c.readFrame(); op != WSTextMessage || string(msg) != "Hello, world" {
+		t.Fatalf("unexpected message %d %q", op, msg)
+	}
+}
+
+func TestWebSocketControlFrameMidMessage(t *testing.T) {
+	c := dialWS(t, newWSTestServer(t, WebSocketConfig{}, nil))
+	c.writeFrame(false, WSTextMessage, []byte("Hel"), true)
+	c.writeFrame(true, WSPingMessage, []byte("ping"), true)
+	if op, msg := c.readFrame(); op != WSPongMessage || string(msg) != "ping" {
+		t.Fatalf("expected pong, got %d %q", op, msg)
+	}
+	c.writeFrame(true, WSPongMessage, nil, true)
+	c.writeFrame(true, wsContinuation, []byte("lo"), true)
+	if op, msg := c.readFrame(); op != WSTextMessage || string(msg) != "Hello" {
+		t.Fatalf("unexpected message %d %q", op, msg)
+	}
+}
+
+func TestWebSocketProtocolErrors(t *testing.T) {
+	cases := []struct {
+		name  string
+		send  func(c *wsTestClient)
+		code  int
+		limit int64
+	}{
+		{"unmasked", func(c *wsTestClient) {
+			c.writeFrame(true, WSTextMessage, []byte("hello"), false)
+		}, WSCloseProtocolError, 0},
+		{"oversize frame", func(c *wsTestClient) {
+			c.writeFrame(true, WSBinaryMessage, make([]byte, 200), true)
+		}, WSCloseMessageTooBig, 100},
+		{"oversize fragmented message", func(c *wsTestClient) {
+			c.writeFrame(false, WSBinaryMessage, make([]byte, 60), true)
+			c.writeFrame(true, wsContinuation, make([]byte, 60), true)
+		}, WSCloseMessageTooBig, 100},
+		{"fragmented control frame", func(c *wsTestClient) {
+			c.writeFrame(false, WSPingMessage, nil, true)
+		}, WSCloseProtocolError, 0},
+		{"continuation without start", func(c *wsTestClient) {
+			c.writeFrame(true, wsContinuation, []byte("x"), true)
+		}, WSCloseProtocolError, 0},
+		{"new message before fin", func(c *wsTestClient) {
+			c.writeFrame(false, WSTextMessage, []byte("a"), true)
+			c.writeFrame(true, WSTextMessage, []byte("b"), true)
+		}, WSCloseProtocolError, 0},
+		{"reserved bits", func(c *wsTestClient) {
+			c.conn.Write([]byte{0x80 | 0x40 | WSTextMessage, 0x80, 0, 0, 0, 0})
+		}, WSCloseProtocolError, 0},
+		{"invalid utf8", func(c *wsTestClient) {
+			c.writeFrame(true, WSTextMessage, []byte{0xff, 0xfe}, true)
+		}, WSCloseInvalidPayload, 0},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := dialWS(t, newWSTestServer(t, WebSocketConfig{MaxMessageSize: tc.limit}, nil))
+			tc.send(c)
+			c.expectClose(tc.code)
+		})
+	}
+}
+
+func TestWebSocketCloseHandshake(t *testing.T) {
+	errCh := make(chan error, 1)
+	srv := newWSTestServer(t, WebSocketConfig{}, func(conn *WSConn) {
+		_, _, err := conn.ReadMessage()
+		errCh <- err
+	})
+	c := dialWS(t, srv)
+	c.writeFrame(true, WSCloseMessage, closePayload(WSCloseGoingAway, "bye"), true)
+	c.expectClose(WSCloseGoingAway)
+
+	var ce *WSCloseError
+	if err := <-errCh; !errors.As(err, &ce) || ce.Code != WSCloseGoingAway || ce.Text != "bye" {
+		t.Fatalf("unexpected read error %v", err)
+	}
+}
+
+func TestWebSocketServerClose(t *testing.T) {
+	srv := newWSTestServer(t, WebSocketConfig{}, func(conn *WSConn) {
+		conn.SendText("bye")
+	})
+	c := dialWS(t, srv)
+	if op, msg := c.readFrame(); op != WSTextMessage || string(msg) != "bye" {
+		t.Fatalf("unexpected message %d %q", op, msg)
+	}
+	c.expectClose(WSCloseNormal)
+}
+
+func TestWebSocketHandshakeRejected(t *testing.T) {
+	srv := newWSTestServer(t, WebSocketConfig{}, nil)
+	resp, err := http.Get(srv.URL + "/ws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", resp.StatusCode)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/ws", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	req.Header.Set("Origin", "https://evil.example.com")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 for cross origin, got %d", resp.StatusCode)
+	}
+}
+
+func TestHubBroadcastAndClose(t *testing.T) {
+	hub := NewHub(HubConfig{})
+	joined := make(chan struct{}, 2)
+	srv := newWSTestServer(t, WebSocketConfig{Hub: hub}, func(conn *WSConn) {
+		conn.Join("room")
+		joined <- struct{}{}
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	})
+	a := dialWS(t, srv)
+	b := dialWS(t, srv)
+	<-joined
+	<-joined
+	if n := hub.Count("room"); n != 2 {
+		t.Fatalf("expected 2 members, got %d", n)
+	}
+	if err := hub.Broadcast("room", WSTextMessage, []byte("hi")); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []*wsTestClient{a, b} {
+		if op, msg := c.readFrame(); op != WSTextMessage || string(msg) != "hi" {
+			t.Fatalf("unexpected broadcast %d %q", op, msg)
+		}
+	}
+
+	// 并发关闭不会panic
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			hub.Close()
+		}()
+	}
+	wg.Wait()
+	a.expectClose(WSCloseGoingAway)
+	b.expectClose(WSCloseGoingAway)
+}
+
+func TestWebSocketSendBufferFull(t *testing.T) {
+	result := make(chan time.Duration, 1)
+	srv := newWSTestServer(t, WebSocketConfig{SendBuffer: 2}, func(conn *WSConn) {
+		data := make([]byte, 1<<20)
+		for {
+			start := time.Now()
+			if err := conn.Send(WSBinaryMessage, data); err != nil {
+				if err == ErrWSBufferFull {
+					result <- time.Since(start)
+				}
+				return
+			}
+			// 留出时间让消息写入连接，直到客户端的接收缓冲区写满
+			time.Sleep(20 * time.Millisecond)
+		}
+	})
+	// 客户端不读取消息，服务端的写入会阻塞到写超时
+	dialWS(t, srv)
+	select {
+	case d := <-result:
+		if d > time.Second {
+			t.Fatalf("send blocked for %v on a full buffer", d)
+		}
+	case <-time.After(8 * time.Second):
+		t.Fatal("send buffer never filled")
+	}
+}
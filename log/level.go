@@ -0,0 +1,129 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Mueat/frm-lib/trace"
+	"github.com/rs/zerolog"
+)
+
+// 自动恢复日志等级的定时器
+var levelTimers = make(map[string]*time.Timer)
+
+// 修改日志等级，不需要重启服务
+// @param string name 日志名称，为空时修改全部日志
+// @param zerolog.Level level 日志等级
+// @param time.Duration duration 生效时长，到期后恢复为配置的等级，0表示一直生效
+func SetLevel(name string, level zerolog.Level, duration time.Duration) error {
+	loggersMu.Lock()
+	defer loggersMu.Unlock()
+	names, err := levelNames(name)
+	if err != nil {
+		return err
+	}
+	for _, n := range names {
+		loggers[n] = loggers[n].Level(level)
+		if t, ok := levelTimers[n]; ok {
+			t.Stop()
+			delete(levelTimers, n)
+		}
+		if duration > 0 {
+			n := n
+			var t *time.Timer
+			t = time.AfterFunc(duration, func() {
+				loggersMu.Lock()
+				defer loggersMu.Unlock()
+				// 定时器已经被新的设置替换
+				if levelTimers[n] != t {
+					return
+				}
+				delete(levelTimers, n)
+				loggers[n] = loggers[n].Level(zerolog.Level(configs[n].LogLevel))
+			})
+			levelTimers[n] = t
+		}
+	}
+	return nil
+}
+
+// 恢复为配置的日志等级
+// @param string name 日志名称，为空时恢复全部日志
+func ResetLevel(name string) error {
+	loggersMu.Lock()
+	defer loggersMu.Unlock()
+	names, err := levelNames(name)
+	if err != nil {
+		return err
+	}
+	for _, n := range names {
+		if t, ok := levelTimers[n]; ok {
+			t.Stop()
+			delete(levelTimers, n)
+		}
+		loggers[n] = loggers[n].Level(zerolog.Level(configs[n].LogLevel))
+	}
+	return nil
+}
+
+// 获取全部日志当前的等级
+func Levels() map[string]string {
+	loggersMu.RLock()
+	defer loggersMu.RUnlock()
+	res := make(map[string]string, len(loggers))
+	for name, l := range loggers {
+		res[name] = l.GetLevel().String()
+	}
+	return res
+}
+
+// 需要修改等级的日志名称，需要持有锁
+func levelNames(name string) ([]string, error) {
+	if name != "" {
+		if _, ok := loggers[name]; !ok {
+			return nil, fmt.Errorf("log: logger %s not found", name)
+		}
+		return []string{name}, nil
+	}
+	names := make([]string, 0, len(loggers))
+	for n := range loggers {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+type debugCtxKey struct{}
+
+// 为单个请求开启debug日志，通过 Ctx 获取的日志处理器会输出debug日志
+func WithDebug(ctx context.Context) context.Context {
+	return context.WithValue(ctx, debugCtxKey{}, true)
+}
+
+// 是否开启了debug日志
+func IsDebug(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	debug, _ := ctx.Value(debugCtxKey{}).(bool)
+	return debug
+}
+
+// 获取请求的日志处理器，记录请求ID，请求开启debug日志时等级为debug
+// @param context.Context ctx 请求的context
+// @param string name 日志名称，为空时使用默认的日志
+func Ctx(ctx context.Context, name string) *zerolog.Logger {
+	l := *Get(name)
+	if ctx == nil {
+		return &l
+	}
+	if IsDebug(ctx) && l.GetLevel() > zerolog.DebugLevel {
+		l = l.Level(zerolog.DebugLevel)
+	}
+	if requestID := trace.FromContext(ctx); requestID != "" {
+		l = l.With().Str("request_id", requestID).Logger()
+	}
+	return &l
+}
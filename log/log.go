@@ -2,39 +2,51 @@ package log
 
 import (
 	"os"
-	"path"
+	"sync"
 
-	rotatelogs "github.com/lestrrat/go-file-rotatelogs"
 	"github.com/rs/zerolog"
 )
 
 var configs map[string]LogConfig
 var loggers map[string]zerolog.Logger
 
+// 运行时可以修改日志等级，loggers 的读写需要加锁
+var loggersMu sync.RWMutex
+
 type LogConfig struct {
 	LogPath         string //保存的日志目录
 	LogName         string //保存的日志文件名称
 	LogLevel        int8   //日志等级
 	AccessLogFormat string //请求日志格式
 	Default         bool   //是否是默认的日志
+	Console         bool   //是否输出带颜色的日志到控制台，一般在开发环境使用，配置了 Sinks 时无效
+	// 输出目标，可以同时输出到文件、控制台、syslog以及TCP/UDP日志收集服务，每个输出可以单独设置日志等级
+	// 为空时输出到 LogPath/LogName 文件
+	Sinks []SinkConfig
+	// 日志文件的轮转配置，支持按时间和大小轮转、按天数和数量清理以及压缩
+	Rotate RotateConfig
 }
 
 // 初始化日志
 // @param map[string]LogConfig confs 日志配置
 func Init(confs map[string]LogConfig) {
-	loggers = make(map[string]zerolog.Logger)
-	configs = confs
+	ls := make(map[string]zerolog.Logger)
 	for name, conf := range confs {
-		logFile := path.Join(conf.LogPath, conf.LogName)
-		rl, err := rotatelogs.New(logFile)
+		w, err := newWriter(name, conf)
 		if err != nil {
 			panic(err)
 		}
-		loggers[name] = zerolog.New(rl).Level(zerolog.Level(conf.LogLevel)).With().Timestamp().Caller().Logger()
+		ls[name] = zerolog.New(w).Level(zerolog.Level(conf.LogLevel)).With().Timestamp().Caller().Logger()
 	}
+	loggersMu.Lock()
+	loggers = ls
+	configs = confs
+	loggersMu.Unlock()
 }
 
 func Has(name string) bool {
+	loggersMu.RLock()
+	defer loggersMu.RUnlock()
 	_, ok := loggers[name]
 	return ok
 }
@@ -56,6 +68,8 @@ func GetConfig(name string) *LogConfig {
 // 获取日志处理器
 // @param string name 日志名称
 func Get(name string) *zerolog.Logger {
+	loggersMu.RLock()
+	defer loggersMu.RUnlock()
 	if name != "" {
 		if l, ok := loggers[name]; ok {
 			return &l
@@ -72,14 +86,16 @@ func Get(name string) *zerolog.Logger {
 	return &logger
 }
 
-// 设置日志钩子
+// 设置日志钩子，对之后通过 Get 等获取的日志处理器生效
 // @param string name 日志名称
 // @param zerolog.Hook hook 钩子
 // @param bool all 是否全部日志处理器都启用该钩子
 func Hook(name string, hook zerolog.Hook, all bool) {
-	for k := range loggers {
+	loggersMu.Lock()
+	defer loggersMu.Unlock()
+	for k, l := range loggers {
 		if all || k == name {
-			loggers[k].Hook(hook)
+			loggers[k] = l.Hook(hook)
 		}
 	}
 }
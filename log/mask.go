@@ -0,0 +1,541 @@
+package log
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// 内置的脱敏规则
+const (
+	MaskMobile   = "mobile"
+	MaskIDCard   = "idcard"
+	MaskBankCard = "bankcard"
+)
+
+// 默认的字段值替换字符串
+const DefaultMaskReplace = "******"
+
+// 循环引用的值替换为该字符串
+const maskCircular = "[circular]"
+
+// 默认需要脱敏的字段名
+var DefaultMaskFields = []string{
+	"password", "passwd", "pwd", "secret", "token", "access_token", "refresh_token",
+	"authorization", "api_key", "private_key",
+}
+
+// 内置规则的正则
+var maskBuiltinPatterns = map[string]string{
+	MaskIDCard:   `\b\d{17}[\dXx]\b`,
+	MaskBankCard: `\b\d{16,19}\b`,
+	MaskMobile:   `\b1[3-9]\d{9}\b`,
+}
+
+// 内置规则的校验，通过校验的内容才脱敏，避免订单号等ID被误脱敏
+var maskBuiltinChecks = map[string]func(string) bool{
+	MaskIDCard:   idCardValid,
+	MaskBankCard: luhnValid,
+}
+
+// 默认使用的内置规则，银行卡号容易和订单号等ID混淆，需要时在 MaskConfig.Patterns 中开启
+var maskBuiltinOrder = []string{MaskIDCard, MaskMobile}
+
+// 日志脱敏配置
+type MaskConfig struct {
+	Disable bool // 是否关闭脱敏
+	// 需要脱敏的字段名，不区分大小写并且忽略 _ 和 -，如 id_card 可以匹配 idCard，为空时使用 DefaultMaskFields
+	// 以配置的名称结尾的字段也会脱敏，如 password 可以匹配 old_password、newPassword
+	Fields []string
+	// 需要脱敏的内容，内置规则 mobile、idcard、bankcard 或者正则表达式，为空时使用 idcard 和 mobile
+	// idcard 只匹配校验位正确的身份证号，bankcard 只匹配通过Luhn校验的卡号，匹配的内容保留前3位和后4位
+	Patterns []string
+	Replace  string // 字段值替换的字符串，默认：******
+}
+
+type masker struct {
+	disable   bool
+	fields    map[string]bool
+	suffixes  []string
+	fieldExpr *regexp.Regexp // 匹配文本中的 field=value、"field":"value" 等
+	patterns  []maskPattern
+	replace   string
+}
+
+type maskPattern struct {
+	re    *regexp.Regexp
+	check func(string) bool
+}
+
+var (
+	mask   *masker
+	maskMu sync.RWMutex
+)
+
+func init() {
+	m, _ := newMasker(MaskConfig{})
+	mask = m
+}
+
+// 设置日志脱敏规则，未设置时使用默认规则
+// @param MaskConfig conf 脱敏配置
+func SetMask(conf MaskConfig) error {
+	m, err := newMasker(conf)
+	if err != nil {
+		return err
+	}
+	maskMu.Lock()
+	mask = m
+	maskMu.Unlock()
+	return nil
+}
+
+func getMasker() *masker {
+	maskMu.RLock()
+	defer maskMu.RUnlock()
+	return mask
+}
+
+func newMasker(conf MaskConfig) (*masker, error) {
+	m := &masker{disable: conf.Disable, fields: make(map[string]bool), replace: conf.Replace}
+	if m.replace == "" {
+		m.replace = DefaultMaskReplace
+	}
+	fields := conf.Fields
+	if len(fields) == 0 {
+		fields = DefaultMaskFields
+	}
+	names := make([]string, 0, len(fields))
+	for _, f := range fields {
+		name := normalizeField(f)
+		if name == "" || m.fields[name] {
+			continue
+		}
+		m.fields[name] = true
+		m.suffixes = append(m.suffixes, name)
+		names = append(names, fieldPattern(name))
+	}
+	m.fieldExpr = regexp.MustCompile("(?i)([\"'`]?\\b[\\w-]*?(?:" + strings.Join(names, "|") + ")\\b[\"'`]?\\s*[:=]\\s*)" +
+		`("(?:[^"\\]|\\.)*"|'(?:[^'\\]|\\.)*'|[^\s&,;)}\]]+)`)
+
+	patterns := conf.Patterns
+	if len(patterns) == 0 {
+		patterns = maskBuiltinOrder
+	}
+	for _, p := range patterns {
+		check := maskBuiltinChecks[p]
+		if expr, ok := maskBuiltinPatterns[p]; ok {
+			p = expr
+		}
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("log: invalid mask pattern %q: %v", p, err)
+		}
+		m.patterns = append(m.patterns, maskPattern{re: re, check: check})
+	}
+	return m, nil
+}
+
+// 字段名统一为小写并去掉 _ 和 -
+func normalizeField(name string) string {
+	return strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(name))
+}
+
+// 字段名的正则，字符之间可以有 _ 和 -，与 normalizeField 的规则一致
+func fieldPattern(name string) string {
+	parts := make([]string, 0, len(name))
+	for _, r := range name {
+		parts = append(parts, regexp.QuoteMeta(string(r)))
+	}
+	return strings.Join(parts, "[_-]*")
+}
+
+// 是否是需要脱敏的字段，字段名以配置的名称结尾时也需要脱敏
+func (m *masker) isField(name string) bool {
+	name = normalizeField(name)
+	if m.fields[name] {
+		return true
+	}
+	for _, suffix := range m.suffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// 替换匹配规则的内容
+func (m *masker) maskPatterns(s string) string {
+	for _, p := range m.patterns {
+		s = p.re.ReplaceAllStringFunc(s, func(match string) string {
+			if p.check != nil && !p.check(match) {
+				return match
+			}
+			return maskMiddle(match)
+		})
+	}
+	return s
+}
+
+// 身份证号的校验位，GB 11643-1999
+func idCardValid(s string) bool {
+	if len(s) != 18 {
+		return false
+	}
+	weights := []int{7, 9, 10, 5, 8, 4, 2, 1, 6, 3, 7, 9, 10, 5, 8, 4, 2}
+	sum := 0
+	for i, w := range weights {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+		sum += int(s[i]-'0') * w
+	}
+	return strings.EqualFold(s[17:], string("10X98765432"[sum%11]))
+}
+
+// 银行卡号的Luhn校验
+func luhnValid(s string) bool {
+	sum := 0
+	double := false
+	for i := len(s) - 1; i >= 0; i-- {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+		d := int(s[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// 保留前3位和后4位，其余替换为 *
+func maskMiddle(s string) string {
+	r := []rune(s)
+	if len(r) <= 7 {
+		return strings.Repeat("*", len(r))
+	}
+	return string(r[:3]) + strings.Repeat("*", len(r)-7) + string(r[len(r)-4:])
+}
+
+// 文本脱敏，用于SQL、请求参数等非JSON的内容
+// 替换 field=value、field = 'value'、"field":"value"、INSERT INTO t (field) VALUES ('value') 中的值以及匹配规则的内容
+func MaskText(s string) string {
+	m := getMasker()
+	if m.disable || s == "" {
+		return s
+	}
+	s = m.maskInsert(s)
+	s = m.fieldExpr.ReplaceAllStringFunc(s, func(match string) string {
+		sub := m.fieldExpr.FindStringSubmatch(match)
+		quote := ""
+		if c := sub[2][0]; c == '"' || c == '\'' {
+			quote = string(c)
+		}
+		return sub[1] + quote + m.replace + quote
+	})
+	return m.maskPatterns(s)
+}
+
+// 匹配 INSERT INTO table (col1, col2) VALUES 的部分
+var insertExpr = regexp.MustCompile("(?i)\\b(?:INSERT|REPLACE)\\s+(?:IGNORE\\s+)?INTO\\s+[^\\s(]+\\s*\\(([^)]*)\\)\\s*VALUES\\s*")
+
+// 替换INSERT语句中需要脱敏的列的值，支持多行 VALUES (...), (...)
+func (m *masker) maskInsert(s string) string {
+	locs := insertExpr.FindAllStringSubmatchIndex(s, -1)
+	if len(locs) == 0 {
+		return s
+	}
+	var b strings.Builder
+	last := 0
+	for _, loc := range locs {
+		if loc[0] < last {
+			continue
+		}
+		cols := strings.Split(s[loc[2]:loc[3]], ",")
+		masked := make(map[int]bool)
+		for i, col := range cols {
+			if m.isField(strings.Trim(strings.TrimSpace(col), "`\"[]")) {
+				masked[i] = true
+			}
+		}
+		if len(masked) == 0 {
+			continue
+		}
+		pos := loc[1]
+		for pos < len(s) && s[pos] == '(' {
+			values, end := splitSQLValues(s, pos)
+			if end < 0 {
+				break
+			}
+			for i, v := range values {
+				if !masked[i] || s[v[0]:v[1]] == "" || strings.EqualFold(s[v[0]:v[1]], "NULL") {
+					continue
+				}
+				b.WriteString(s[last:v[0]])
+				if c := s[v[0]]; c == '\'' || c == '"' {
+					b.WriteString(string(c) + m.replace + string(c))
+				} else {
+					b.WriteString(m.replace)
+				}
+				last = v[1]
+			}
+			pos = end
+			for pos < len(s) && (s[pos] == ' ' || s[pos] == ',' || s[pos] == '\n' || s[pos] == '\t') {
+				pos++
+			}
+		}
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// 解析 (v1, 'v2', f(v3)) 中每个值的位置，返回值的起止位置以及右括号之后的位置，格式错误时返回 -1
+func splitSQLValues(s string, start int) ([][2]int, int) {
+	values := make([][2]int, 0)
+	depth := 0
+	valueStart := start + 1
+	add := func(end int) {
+		from, to := valueStart, end
+		for from < to && isSQLSpace(s[from]) {
+			from++
+		}
+		for to > from && isSQLSpace(s[to-1]) {
+			to--
+		}
+		values = append(values, [2]int{from, to})
+	}
+	for i := start + 1; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\'', '"':
+			// 引号中的内容，支持 '' 和 \' 转义
+			for i++; i < len(s); i++ {
+				if s[i] == '\\' {
+					i++
+				} else if s[i] == c {
+					if i+1 < len(s) && s[i+1] == c {
+						i++
+						continue
+					}
+					break
+				}
+			}
+		case '(':
+			depth++
+		case ')':
+			if depth > 0 {
+				depth--
+				continue
+			}
+			add(i)
+			return values, i + 1
+		case ',':
+			if depth == 0 {
+				add(i)
+				valueStart = i + 1
+			}
+		}
+	}
+	return nil, -1
+}
+
+func isSQLSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// 请求body脱敏，JSON格式时按字段名和规则脱敏，否则按文本脱敏
+func MaskBody(body []byte) string {
+	m := getMasker()
+	if m.disable || len(body) == 0 {
+		return string(body)
+	}
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 || (trimmed[0] != '{' && trimmed[0] != '[') {
+		return MaskText(string(body))
+	}
+	dec := json.NewDecoder(bytes.NewReader(trimmed))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil || dec.More() {
+		return MaskText(string(body))
+	}
+	data, err := marshalNoEscape(m.maskValue(reflect.ValueOf(v), make(map[maskVisit]bool)))
+	if err != nil {
+		return MaskText(string(body))
+	}
+	return string(data)
+}
+
+// 返回脱敏后的数据，用于记录结构体、map等，如：
+//
+//	log.Info().Interface("user", log.Mask(user)).Send()
+//
+// 结构体中标记了 `log:"mask"` 的字段以及字段名需要脱敏的字段替换为 Replace，字符串按规则脱敏
+// 结构体转换为 map，字段名使用json标签
+func Mask(v interface{}) interface{} {
+	m := getMasker()
+	if m.disable || v == nil {
+		return v
+	}
+	return m.maskValue(reflect.ValueOf(v), make(map[maskVisit]bool))
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	jsonNumberType    = reflect.TypeOf(json.Number(""))
+)
+
+// 已经访问的指针，用于发现循环引用
+type maskVisit struct {
+	ptr uintptr
+	typ reflect.Type
+}
+
+// @param map[maskVisit]bool seen 当前路径上的指针、map和切片，重复出现时替换为 [circular]
+func (m *masker) maskValue(rv reflect.Value, seen map[maskVisit]bool) interface{} {
+	if !rv.IsValid() {
+		return nil
+	}
+	// JSON中的数字按规则脱敏，脱敏后转换为字符串
+	if rv.Type() == jsonNumberType {
+		s := rv.String()
+		if masked := m.maskPatterns(s); masked != s {
+			return masked
+		}
+		return rv.Interface()
+	}
+	// time.Time 等自定义序列化的类型保持不变
+	if rv.Kind() != reflect.Interface && (rv.Type().Implements(jsonMarshalerType) || rv.Type().Implements(textMarshalerType)) {
+		if rv.Kind() == reflect.Ptr && rv.IsNil() {
+			return nil
+		}
+		return rv.Interface()
+	}
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice:
+		if rv.IsNil() {
+			return nil
+		}
+		visit := maskVisit{ptr: rv.Pointer(), typ: rv.Type()}
+		if seen[visit] {
+			return maskCircular
+		}
+		seen[visit] = true
+		defer delete(seen, visit)
+	}
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			return nil
+		}
+		return m.maskValue(rv.Elem(), seen)
+	case reflect.Struct:
+		res := make(map[string]interface{})
+		m.maskStruct(rv, res, seen)
+		return res
+	case reflect.Map:
+		res := make(map[string]interface{}, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			k := fmt.Sprint(iter.Key().Interface())
+			if m.isField(k) {
+				res[k] = m.replace
+			} else {
+				res[k] = m.maskValue(iter.Value(), seen)
+			}
+		}
+		return res
+	case reflect.Slice, reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return rv.Interface()
+		}
+		res := make([]interface{}, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			res[i] = m.maskValue(rv.Index(i), seen)
+		}
+		return res
+	case reflect.String:
+		return m.maskPatterns(rv.String())
+	}
+	if rv.CanInterface() {
+		return rv.Interface()
+	}
+	return nil
+}
+
+// 结构体的字段写入map，匿名结构体的字段合并到上一层
+func (m *masker) maskStruct(rv reflect.Value, res map[string]interface{}, seen map[maskVisit]bool) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		fv := rv.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		name, opts := field.Name, ""
+		if tag, ok := field.Tag.Lookup("json"); ok {
+			if tag == "-" {
+				continue
+			}
+			parts := strings.SplitN(tag, ",", 2)
+			if parts[0] != "" {
+				name = parts[0]
+			}
+			if len(parts) > 1 {
+				opts = parts[1]
+			}
+		}
+		if field.Anonymous && name == field.Name {
+			ev := fv
+			if ev.Kind() == reflect.Ptr {
+				if ev.IsNil() {
+					continue
+				}
+				visit := maskVisit{ptr: ev.Pointer(), typ: ev.Type()}
+				if seen[visit] {
+					continue
+				}
+				seen[visit] = true
+				defer delete(seen, visit)
+				ev = ev.Elem()
+			}
+			if ev.Kind() == reflect.Struct {
+				m.maskStruct(ev, res, seen)
+				continue
+			}
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		if strings.Contains(opts, "omitempty") && fv.IsZero() {
+			continue
+		}
+		if field.Tag.Get("log") == "mask" || m.isField(name) {
+			res[name] = m.replace
+			continue
+		}
+		res[name] = m.maskValue(fv, seen)
+	}
+}
+
+// 序列化为JSON，不转义HTML字符
+func marshalNoEscape(v interface{}) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
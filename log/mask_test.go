@@ -0,0 +1,227 @@
+package log
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func setTestMask(t *testing.T, conf MaskConfig) {
+	t.Helper()
+	if err := SetMask(conf); err != nil {
+		t.Fatalf("set mask: %v", err)
+	}
+	t.Cleanup(func() {
+		SetMask(MaskConfig{})
+	})
+}
+
+func TestMaskText(t *testing.T) {
+	setTestMask(t, MaskConfig{})
+	cases := []struct {
+		name string
+		in   string
+		out  string
+	}{
+		{"query", "a=1&password=123456&b=2", "a=1&password=******&b=2"},
+		{"url", "https://api.example.com/cgi?access_token=abc&x=1", "https://api.example.com/cgi?access_token=******&x=1"},
+		{"camel case", "accessToken=abc", "accessToken=******"},
+		{"upper case", "PASSWORD: abc", "PASSWORD: ******"},
+		{"json", `{"token":"abc","name":"bob"}`, `{"token":"******","name":"bob"}`},
+		{"suffix", "old_password=a&newPassword=b&client_secret=c", "old_password=******&newPassword=******&client_secret=******"},
+		{"not suffix", "password_hint=abc&tokens=1", "password_hint=abc&tokens=1"},
+		{"sql where", "SELECT * FROM users WHERE password = 'hunter2' AND id = 1", "SELECT * FROM users WHERE password = '******' AND id = 1"},
+		{"sql update", "UPDATE `users` SET `password`='hunter2',`name`='bob' WHERE id = 1", "UPDATE `users` SET `password`='******',`name`='bob' WHERE id = 1"},
+		{"sql insert", "INSERT INTO users (name,password) VALUES ('bob','hunter2')", "INSERT INTO users (name,password) VALUES ('bob','******')"},
+		{"sql insert quoted", "INSERT INTO `users` (`name`,`password`,`age`) VALUES ('bob','it''s, (secret)',18)", "INSERT INTO `users` (`name`,`password`,`age`) VALUES ('bob','******',18)"},
+		{"sql insert rows", "INSERT INTO users (password, name) VALUES ('a', 'bob'), ('b', 'tom') ON CONFLICT DO NOTHING", "INSERT INTO users (password, name) VALUES ('******', 'bob'), ('******', 'tom') ON CONFLICT DO NOTHING"},
+		{"sql insert null", `INSERT INTO "users" ("name","password") VALUES ('bob',NULL)`, `INSERT INTO "users" ("name","password") VALUES ('bob',NULL)`},
+		{"sql insert other", "INSERT INTO users (name,age) VALUES ('bob',18)", "INSERT INTO users (name,age) VALUES ('bob',18)"},
+		{"mobile", "mobile 13812345678", "mobile 138****5678"},
+		{"idcard", "id 110101199003074514", "id 110***********4514"},
+		{"idcard checksum", "id 110101199003074515", "id 110101199003074515"},
+		{"snowflake id", "order 123456789012345678", "order 123456789012345678"},
+		{"bankcard disabled", "card 6222021234567890128", "card 6222021234567890128"},
+	}
+	for _, c := range cases {
+		if got := MaskText(c.in); got != c.out {
+			t.Errorf("%s: MaskText(%q) = %q, want %q", c.name, c.in, got, c.out)
+		}
+	}
+}
+
+func TestMaskFields(t *testing.T) {
+	setTestMask(t, MaskConfig{Fields: []string{"id_card"}, Replace: "***"})
+	cases := []struct {
+		in  string
+		out string
+	}{
+		{"id_card=1", "id_card=***"},
+		{"idCard=1", "idCard=***"},
+		{"ID-CARD: 1", "ID-CARD: ***"},
+		{"user_id_card=1", "user_id_card=***"},
+		{"password=1", "password=1"},
+	}
+	for _, c := range cases {
+		if got := MaskText(c.in); got != c.out {
+			t.Errorf("MaskText(%q) = %q, want %q", c.in, got, c.out)
+		}
+	}
+}
+
+func TestMaskPatterns(t *testing.T) {
+	setTestMask(t, MaskConfig{Patterns: []string{MaskBankCard, `\bSN\d{6}\b`}})
+	cases := []struct {
+		in  string
+		out string
+	}{
+		{"card 6222021234567890128", "card 622************0128"},
+		{"card 6222021234567890123", "card 6222021234567890123"},
+		{"sn SN123456", "sn SN1*3456"},
+		{"mobile 13812345678", "mobile 13812345678"},
+	}
+	for _, c := range cases {
+		if got := MaskText(c.in); got != c.out {
+			t.Errorf("MaskText(%q) = %q, want %q", c.in, got, c.out)
+		}
+	}
+	if err := SetMask(MaskConfig{Patterns: []string{"("}}); err == nil {
+		t.Fatal("invalid pattern should be rejected")
+	}
+}
+
+func TestMaskChecks(t *testing.T) {
+	cases := []struct {
+		check func(string) bool
+		in    string
+		valid bool
+	}{
+		{idCardValid, "110101199003074514", true},
+		{idCardValid, "11010119900307451X", false},
+		{idCardValid, "11010519491231002x", true},
+		{idCardValid, "1101011990030745", false},
+		{luhnValid, "6222021234567890128", true},
+		{luhnValid, "4111111111111111", true},
+		{luhnValid, "4111111111111112", false},
+	}
+	for _, c := range cases {
+		if got := c.check(c.in); got != c.valid {
+			t.Errorf("check(%s) = %v, want %v", c.in, got, c.valid)
+		}
+	}
+}
+
+func TestMaskBody(t *testing.T) {
+	setTestMask(t, MaskConfig{})
+	cases := []struct {
+		name string
+		in   string
+		out  string
+	}{
+		{"fields", `{"name":"bob","password":"123"}`, `{"name":"bob","password":"******"}`},
+		{"suffix", `{"old_password":"a","newPassword":"b"}`, `{"newPassword":"******","old_password":"******"}`},
+		{"nested", `[{"user":{"token":"abc","mobile":"13812345678"}}]`, `[{"user":{"mobile":"138****5678","token":"******"}}]`},
+		{"number", `{"mobile":13812345678,"count":123,"order":1234567890123456789}`, `{"count":123,"mobile":"138****5678","order":1234567890123456789}`},
+		{"html", `{"url":"a?b=1&c=<d>"}`, `{"url":"a?b=1&c=<d>"}`},
+		{"form", "a=1&password=2", "a=1&password=******"},
+		{"invalid json", `{"password":"1"`, `{"password":"******"`},
+	}
+	for _, c := range cases {
+		if got := MaskBody([]byte(c.in)); got != c.out {
+			t.Errorf("%s: MaskBody(%s) = %s, want %s", c.name, c.in, got, c.out)
+		}
+	}
+}
+
+type maskNode struct {
+	Name string    `json:"name"`
+	Next *maskNode `json:"next"`
+}
+
+type maskBase struct {
+	ID       int    `json:"id"`
+	Password string `json:"password"`
+}
+
+type maskUser struct {
+	maskBase
+	Name      string                 `json:"name"`
+	Mobile    string                 `json:"mobile"`
+	Address   string                 `json:"address" log:"mask"`
+	Email     string                 `json:"email,omitempty"`
+	Ignored   string                 `json:"-"`
+	CreatedAt time.Time              `json:"created_at"`
+	Extra     map[string]interface{} `json:"extra"`
+	private   string
+}
+
+func TestMask(t *testing.T) {
+	setTestMask(t, MaskConfig{})
+	created := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+	user := &maskUser{
+		maskBase:  maskBase{ID: 1, Password: "123"},
+		Name:      "bob",
+		Mobile:    "13812345678",
+		Address:   "somewhere",
+		Ignored:   "x",
+		CreatedAt: created,
+		Extra:     map[string]interface{}{"api_key": "k", "n": 1},
+		private:   "p",
+	}
+	data, err := json.Marshal(Mask(user))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"address":"******","created_at":"2026-10-19T10:00:00Z","extra":{"api_key":"******","n":1},"id":1,"mobile":"138****5678","name":"bob","password":"******"}`
+	if string(data) != expected {
+		t.Fatalf("unexpected masked value:\n%s\n%s", data, expected)
+	}
+	if Mask(nil) != nil {
+		t.Fatal("nil should stay nil")
+	}
+}
+
+func TestMaskCycle(t *testing.T) {
+	setTestMask(t, MaskConfig{})
+	n := &maskNode{Name: "a"}
+	n.Next = n
+	data, err := json.Marshal(Mask(n))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"name":"a","next":"[circular]"}` {
+		t.Fatalf("unexpected masked value: %s", data)
+	}
+
+	m := map[string]interface{}{"name": "a"}
+	m["self"] = m
+	s := []interface{}{1, nil}
+	s[1] = s
+	data, err = json.Marshal(Mask([]interface{}{m, s}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `[{"name":"a","self":"[circular]"},[1,"[circular]"]]` {
+		t.Fatalf("unexpected masked value: %s", data)
+	}
+
+	// 同一个指针出现在不同的位置不是循环引用
+	shared := &maskNode{Name: "b"}
+	data, err = json.Marshal(Mask([]*maskNode{shared, shared}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `[{"name":"b","next":null},{"name":"b","next":null}]` {
+		t.Fatalf("unexpected masked value: %s", data)
+	}
+}
+
+func TestMaskDisable(t *testing.T) {
+	setTestMask(t, MaskConfig{Disable: true})
+	if got := MaskText("password=1"); got != "password=1" {
+		t.Fatalf("unexpected masked text: %s", got)
+	}
+	if got := MaskBody([]byte(`{"password":"1"}`)); got != `{"password":"1"}` {
+		t.Fatalf("unexpected masked body: %s", got)
+	}
+}
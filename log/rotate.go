@@ -0,0 +1,349 @@
+package log
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	strftime "github.com/lestrrat/go-strftime"
+)
+
+// 常用的时间格式
+const (
+	RotateHourly = "hourly"
+	RotateDaily  = "daily"
+)
+
+const compressSuffix = ".gz"
+
+// 时钟，测试时可以替换为固定的时间
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// strftime格式中的时间，QuoteMeta后 % 保持不变
+var strftimeVerb = regexp.MustCompile(`%[A-Za-z%]`)
+
+// strftime格式对应的正则，用于匹配轮转后的文件，避免匹配到同目录下其他日志的文件
+var strftimeExpr = map[byte]string{
+	'Y': `\d{4}`, 'C': `\d{2}`, 'y': `\d{2}`, 'm': `\d{2}`, 'd': `\d{2}`, 'H': `\d{2}`, 'M': `\d{2}`, 'S': `\d{2}`,
+	'U': `\d{2}`, 'V': `\d{2}`, 'W': `\d{2}`, 'j': `\d{3}`, 'u': `\d`, 'w': `\d`, 'I': `\d{1,2}`,
+	'e': `[ \d]\d`, 'k': `[ \d]\d`, 'l': `[ \d]\d`,
+	'F': `\d{4}-\d{2}-\d{2}`, 'R': `\d{2}:\d{2}`, 'T': `\d{2}:\d{2}:\d{2}`, 'X': `\d{2}:\d{2}:\d{2}`,
+	'D': `\d{2}/\d{2}/\d{2}`, 'x': `\d{2}/\d{2}/\d{2}`, 'z': `[+-]\d{4}`,
+	'A': `[A-Za-z]+`, 'a': `[A-Za-z]+`, 'B': `[A-Za-z]+`, 'b': `[A-Za-z]+`, 'h': `[A-Za-z]+`, 'p': `[AP]M`, 'Z': `[A-Za-z]+`,
+	'n': `\n`, 't': `\t`, '%': `%`,
+}
+
+// 日志轮转配置
+type RotateConfig struct {
+	// 按时间轮转的文件名时间格式，hourly、daily 或者 strftime 格式如 %Y%m%d%H，时间插入到文件扩展名之前
+	// 为空时不按时间轮转，日志文件名中包含 % 时直接使用文件名作为格式
+	Pattern  string
+	MaxSize  int64  // 单个文件的最大大小，单位：MB，超过后写入新的文件，0表示不限制
+	MaxAge   int64  // 轮转后的文件保留天数，0表示不限制
+	MaxCount int    // 轮转后的文件保留数量，0表示不限制
+	Compress bool   // 是否使用gzip压缩轮转后的文件
+	LinkName string // 指向当前日志文件的软链接，相对路径时在日志目录中创建，为空时不创建
+	Clock    Clock  `toml:"-" json:"-"` // 时钟，默认使用系统时间
+}
+
+// 支持按时间和大小轮转的日志文件
+//
+// 同一个时间段内超过 MaxSize 时写入带序号的文件，如 app.2021080110.log、app.2021080110.1.log
+type RotateWriter struct {
+	conf    RotateConfig
+	dir     string
+	pattern *strftime.Strftime
+	ext     string
+	match   *regexp.Regexp // 匹配所有的日志文件
+
+	mu      sync.Mutex
+	file    *os.File
+	current string // 当前的日志文件，关闭后保留用于清理时跳过
+	base    string // 当前时间段的文件名，不包含序号
+	index   int
+	size    int64
+	bg      sync.WaitGroup
+	bgMu    sync.Mutex // 压缩和清理不能同时进行
+}
+
+// 创建轮转日志文件
+// @param string filename 日志文件路径，如 ./logs/app.log
+// @param RotateConfig conf 轮转配置
+func NewRotateWriter(filename string, conf RotateConfig) (*RotateWriter, error) {
+	if conf.Clock == nil {
+		conf.Clock = systemClock{}
+	}
+	switch conf.Pattern {
+	case RotateHourly:
+		conf.Pattern = "%Y%m%d%H"
+	case RotateDaily:
+		conf.Pattern = "%Y%m%d"
+	}
+
+	dir, name := filepath.Split(filename)
+	if dir == "" {
+		dir = "."
+	}
+	ext := filepath.Ext(name)
+	if strings.Contains(ext, "%") {
+		ext = ""
+	}
+	namePattern := name
+	if !strings.Contains(name, "%") && conf.Pattern != "" {
+		namePattern = strings.TrimSuffix(name, ext) + "." + conf.Pattern + ext
+	}
+	pattern, err := strftime.New(namePattern)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	// 文件名中的时间格式替换为对应的正则，再加上可选的序号和压缩后缀
+	stem := strings.TrimSuffix(namePattern, ext)
+	expr := strftimeVerb.ReplaceAllStringFunc(regexp.QuoteMeta(stem), func(verb string) string {
+		if e, ok := strftimeExpr[verb[len(verb)-1]]; ok {
+			return e
+		}
+		return ".+?"
+	})
+	expr = "^" + expr + `(\.\d+)?` + regexp.QuoteMeta(ext) + "(" + regexp.QuoteMeta(compressSuffix) + ")?$"
+
+	w := &RotateWriter{
+		conf:    conf,
+		dir:     dir,
+		pattern: pattern,
+		ext:     ext,
+		match:   regexp.MustCompile(expr),
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.open(w.conf.Clock.Now(), false); err != nil {
+		return nil, err
+	}
+	w.bg.Add(1)
+	go func() {
+		defer w.bg.Done()
+		w.bgMu.Lock()
+		defer w.bgMu.Unlock()
+		w.clean()
+	}()
+	return w, nil
+}
+
+func (w *RotateWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	now := w.conf.Clock.Now()
+	if w.pattern.FormatString(now) != w.base {
+		if err := w.open(now, false); err != nil {
+			return 0, err
+		}
+	} else if w.conf.MaxSize > 0 && w.size > 0 && w.size+int64(len(p)) > w.conf.MaxSize*1024*1024 {
+		if err := w.open(now, true); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// 当前的日志文件
+func (w *RotateWriter) CurrentFileName() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.current
+}
+
+// 关闭日志文件，等待压缩和清理完成
+func (w *RotateWriter) Close() error {
+	w.mu.Lock()
+	var err error
+	if w.file != nil {
+		err = w.file.Close()
+		w.file = nil
+	}
+	w.mu.Unlock()
+	w.bg.Wait()
+	return err
+}
+
+// 打开新的日志文件，需要持有锁
+// @param bool next 是否因为大小超过限制切换到下一个序号
+func (w *RotateWriter) open(now time.Time, next bool) error {
+	base := w.pattern.FormatString(now)
+	index := 0
+	if next && base == w.base {
+		index = w.index + 1
+	}
+	// 跳过已经写满或者已经压缩的文件
+	var size int64
+	for {
+		name := w.fileName(base, index)
+		if _, err := os.Stat(name + compressSuffix); err == nil {
+			index++
+			continue
+		}
+		info, err := os.Stat(name)
+		if err == nil && w.conf.MaxSize > 0 && info.Size() >= w.conf.MaxSize*1024*1024 {
+			index++
+			continue
+		}
+		if err == nil {
+			size = info.Size()
+		}
+		break
+	}
+
+	name := w.fileName(base, index)
+	if w.file != nil && w.file.Name() == name {
+		return nil
+	}
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	prev := w.file
+	w.file, w.current, w.base, w.index, w.size = f, name, base, index, size
+	w.link(name)
+
+	if prev != nil {
+		prevName := prev.Name()
+		prev.Close()
+		// 修改时间设置为轮转的时间，按保留天数清理时使用
+		os.Chtimes(prevName, now, now)
+		w.bg.Add(1)
+		go func() {
+			defer w.bg.Done()
+			w.bgMu.Lock()
+			defer w.bgMu.Unlock()
+			if w.conf.Compress {
+				compressFile(prevName)
+			}
+			w.clean()
+		}()
+	}
+	return nil
+}
+
+// 带序号的文件名
+func (w *RotateWriter) fileName(base string, index int) string {
+	if index > 0 {
+		base = strings.TrimSuffix(base, w.ext) + "." + strconv.Itoa(index) + w.ext
+	}
+	return filepath.Join(w.dir, base)
+}
+
+// 更新指向当前文件的软链接
+func (w *RotateWriter) link(name string) {
+	if w.conf.LinkName == "" {
+		return
+	}
+	linkName := w.conf.LinkName
+	if !filepath.IsAbs(linkName) {
+		linkName = filepath.Join(w.dir, linkName)
+	}
+	target := name
+	if filepath.Dir(linkName) == filepath.Dir(name) {
+		target = filepath.Base(name)
+	} else if abs, err := filepath.Abs(name); err == nil {
+		target = abs
+	}
+	tmp := linkName + ".tmp"
+	os.Remove(tmp)
+	if err := os.Symlink(target, tmp); err != nil {
+		return
+	}
+	if err := os.Rename(tmp, linkName); err != nil {
+		os.Remove(tmp)
+	}
+}
+
+// 按保留天数和数量删除轮转后的文件，需要持有 bgMu
+func (w *RotateWriter) clean() {
+	if w.conf.MaxAge <= 0 && w.conf.MaxCount <= 0 {
+		return
+	}
+
+	current := w.CurrentFileName()
+	files, err := ioutil.ReadDir(w.dir)
+	if err != nil {
+		return
+	}
+	rotated := make([]os.FileInfo, 0)
+	for _, f := range files {
+		if !f.Mode().IsRegular() || !w.match.MatchString(f.Name()) {
+			continue
+		}
+		if filepath.Join(w.dir, f.Name()) == current {
+			continue
+		}
+		rotated = append(rotated, f)
+	}
+	sort.Slice(rotated, func(i, j int) bool {
+		return rotated[i].ModTime().After(rotated[j].ModTime())
+	})
+	cutoff := w.conf.Clock.Now().Add(-time.Duration(w.conf.MaxAge) * 24 * time.Hour)
+	for i, f := range rotated {
+		if (w.conf.MaxCount > 0 && i >= w.conf.MaxCount) || (w.conf.MaxAge > 0 && f.ModTime().Before(cutoff)) {
+			os.Remove(filepath.Join(w.dir, f.Name()))
+		}
+	}
+}
+
+// 使用gzip压缩文件，成功后删除原文件
+func compressFile(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	tmp := name + compressSuffix + ".tmp"
+	dst, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode())
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(dst)
+	gz.Name = filepath.Base(name)
+	gz.ModTime = info.ModTime()
+	if _, err = io.Copy(gz, src); err == nil {
+		err = gz.Close()
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, name+compressSuffix); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	// 保留原文件的修改时间，用于按保留天数清理
+	os.Chtimes(name+compressSuffix, info.ModTime(), info.ModTime())
+	return os.Remove(name)
+}
@@ -0,0 +1,249 @@
+package log
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Set(t time.Time) {
+	c.mu.Lock()
+	c.now = t
+	c.mu.Unlock()
+}
+
+func newFakeClock(value string) *fakeClock {
+	return &fakeClock{now: parseTime(value)}
+}
+
+func parseTime(value string) time.Time {
+	t, err := time.ParseInLocation("2006-01-02 15:04:05", value, time.Local)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func newTestRotateWriter(t *testing.T, conf RotateConfig) (*RotateWriter, string) {
+	t.Helper()
+	dir := t.TempDir()
+	w, err := NewRotateWriter(filepath.Join(dir, "app.log"), conf)
+	if err != nil {
+		t.Fatalf("new rotate writer: %v", err)
+	}
+	return w, dir
+}
+
+func write(t *testing.T, w *RotateWriter, s string) {
+	t.Helper()
+	if _, err := w.Write([]byte(s)); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+}
+
+func listFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0, len(files))
+	for _, f := range files {
+		names = append(names, f.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func assertFiles(t *testing.T, dir string, expected ...string) {
+	t.Helper()
+	sort.Strings(expected)
+	names := listFiles(t, dir)
+	if len(names) != len(expected) {
+		t.Fatalf("expected files %v, got %v", expected, names)
+	}
+	for i := range names {
+		if names[i] != expected[i] {
+			t.Fatalf("expected files %v, got %v", expected, names)
+		}
+	}
+}
+
+func assertContent(t *testing.T, name string, expected string) {
+	t.Helper()
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != expected {
+		t.Fatalf("unexpected content of %s: %q", filepath.Base(name), data)
+	}
+}
+
+func TestRotateHourly(t *testing.T) {
+	clock := newFakeClock("2026-10-19 10:59:59")
+	w, dir := newTestRotateWriter(t, RotateConfig{Pattern: RotateHourly, Clock: clock})
+	write(t, w, "a\n")
+	clock.Set(parseTime("2026-10-19 11:00:00"))
+	write(t, w, "b\n")
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	assertFiles(t, dir, "app.2026101910.log", "app.2026101911.log")
+	assertContent(t, filepath.Join(dir, "app.2026101910.log"), "a\n")
+	assertContent(t, filepath.Join(dir, "app.2026101911.log"), "b\n")
+}
+
+func TestRotateDaily(t *testing.T) {
+	clock := newFakeClock("2026-10-19 23:59:59")
+	w, dir := newTestRotateWriter(t, RotateConfig{Pattern: RotateDaily, Clock: clock})
+	write(t, w, "a\n")
+	clock.Set(parseTime("2026-10-20 00:00:00"))
+	write(t, w, "b\n")
+	clock.Set(parseTime("2026-10-20 12:00:00"))
+	write(t, w, "c\n")
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	assertFiles(t, dir, "app.20261019.log", "app.20261020.log")
+	assertContent(t, filepath.Join(dir, "app.20261020.log"), "b\nc\n")
+}
+
+func TestRotateMaxSize(t *testing.T) {
+	clock := newFakeClock("2026-10-19 10:00:00")
+	w, dir := newTestRotateWriter(t, RotateConfig{Pattern: RotateHourly, MaxSize: 1, Clock: clock})
+	chunk := string(bytes.Repeat([]byte("x"), 600*1024))
+	write(t, w, chunk)
+	write(t, w, chunk)
+	write(t, w, chunk)
+	if name := filepath.Base(w.CurrentFileName()); name != "app.2026101910.2.log" {
+		t.Fatalf("unexpected current file: %s", name)
+	}
+	// 新的时间段重新从没有序号的文件开始
+	clock.Set(parseTime("2026-10-19 11:00:00"))
+	write(t, w, "a\n")
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	assertFiles(t, dir, "app.2026101910.log", "app.2026101910.1.log", "app.2026101910.2.log", "app.2026101911.log")
+}
+
+func TestRotateMaxCount(t *testing.T) {
+	clock := newFakeClock("2026-10-19 10:00:00")
+	w, dir := newTestRotateWriter(t, RotateConfig{Pattern: RotateHourly, MaxCount: 2, Clock: clock})
+	// 同目录下其他日志的文件不会被清理
+	sibling := filepath.Join(dir, "app.error.2026101900.log")
+	if err := ioutil.WriteFile(sibling, []byte("error\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for hour := 10; hour <= 14; hour++ {
+		clock.Set(parseTime("2026-10-19 10:00:00").Add(time.Duration(hour-10) * time.Hour))
+		write(t, w, "a\n")
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	assertFiles(t, dir, "app.2026101912.log", "app.2026101913.log", "app.2026101914.log", "app.error.2026101900.log")
+}
+
+func TestRotateMaxAge(t *testing.T) {
+	clock := newFakeClock("2026-10-10 10:00:00")
+	w, dir := newTestRotateWriter(t, RotateConfig{Pattern: RotateDaily, MaxAge: 3, Clock: clock})
+	for day := 10; day <= 19; day += 3 {
+		clock.Set(parseTime("2026-10-10 10:00:00").AddDate(0, 0, day-10))
+		write(t, w, "a\n")
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// 按轮转的时间计算，20261010 在13日轮转，早于3天前被删除
+	assertFiles(t, dir, "app.20261013.log", "app.20261016.log", "app.20261019.log")
+}
+
+func TestRotateCompress(t *testing.T) {
+	clock := newFakeClock("2026-10-19 10:00:00")
+	w, dir := newTestRotateWriter(t, RotateConfig{Pattern: RotateHourly, Compress: true, Clock: clock})
+	write(t, w, "a\n")
+	clock.Set(parseTime("2026-10-19 11:00:00"))
+	write(t, w, "b\n")
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	assertFiles(t, dir, "app.2026101910.log.gz", "app.2026101911.log")
+
+	f, err := os.Open(filepath.Join(dir, "app.2026101910.log.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "a\n" || gz.Name != "app.2026101910.log" {
+		t.Fatalf("unexpected gzip content: %q %s", data, gz.Name)
+	}
+}
+
+func TestRotateLink(t *testing.T) {
+	clock := newFakeClock("2026-10-19 10:00:00")
+	w, dir := newTestRotateWriter(t, RotateConfig{Pattern: RotateHourly, LinkName: "current.log", Clock: clock})
+	defer w.Close()
+	link := filepath.Join(dir, "current.log")
+	if target, err := os.Readlink(link); err != nil || target != "app.2026101910.log" {
+		t.Fatalf("unexpected link target: %s %v", target, err)
+	}
+	clock.Set(parseTime("2026-10-19 11:00:00"))
+	write(t, w, "b\n")
+	if target, err := os.Readlink(link); err != nil || target != "app.2026101911.log" {
+		t.Fatalf("unexpected link target after rotate: %s %v", target, err)
+	}
+	assertContent(t, link, "b\n")
+}
+
+func TestRotateMatch(t *testing.T) {
+	cases := []struct {
+		pattern string
+		name    string
+		match   bool
+	}{
+		{RotateHourly, "app.2026101910.log", true},
+		{RotateHourly, "app.2026101910.3.log", true},
+		{RotateHourly, "app.2026101910.log.gz", true},
+		{RotateHourly, "app.error.2026101910.log", false},
+		{RotateHourly, "app.20261019.log", false},
+		{RotateHourly, "app.log", false},
+		{RotateDaily, "app.20261019.log", true},
+		{RotateDaily, "app.2026101910.log", false},
+		{"%Y-%m-%d", "app.2026-10-19.log", true},
+		{"%Y-%m-%d", "app.error-10-19.log", false},
+	}
+	for _, c := range cases {
+		w, _ := newTestRotateWriter(t, RotateConfig{Pattern: c.pattern, Clock: newFakeClock("2026-10-19 10:00:00")})
+		w.Close()
+		if w.match.MatchString(c.name) != c.match {
+			t.Errorf("pattern %s, name %s: expected match %v", c.pattern, c.name, c.match)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package log
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// 通过信号修改日志等级：SIGUSR1 将全部日志设置为debug，SIGUSR2 恢复为配置的等级
+//
+//	kill -USR1 <pid>
+//
+// @param time.Duration duration SIGUSR1 的生效时长，到期后自动恢复，0表示一直生效
+func HandleSignals(duration time.Duration) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGUSR1, syscall.SIGUSR2)
+	go func() {
+		for sig := range ch {
+			var err error
+			if sig == syscall.SIGUSR1 {
+				err = SetLevel("", zerolog.DebugLevel, duration)
+			} else {
+				err = ResetLevel("")
+			}
+			if err != nil {
+				Error().Err(err).Str("type", "LOG").Str("name", "signal").Str("method", "HandleSignals").Send()
+			} else {
+				Warn().Str("type", "LOG").Str("name", "signal").Str("method", "HandleSignals").Str("signal", sig.String()).Msg("log level changed")
+			}
+		}
+	}()
+}
@@ -0,0 +1,9 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package log
+
+import "time"
+
+// 当前平台不支持 SIGUSR1 和 SIGUSR2
+func HandleSignals(duration time.Duration) {}
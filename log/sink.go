@@ -0,0 +1,200 @@
+package log
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// 输出类型
+const (
+	SinkFile    = "file"
+	SinkStdout  = "stdout"
+	SinkStderr  = "stderr"
+	SinkConsole = "console"
+	SinkSyslog  = "syslog"
+	SinkTCP     = "tcp"
+	SinkUDP     = "udp"
+)
+
+const (
+	DefaultSinkTimeout = 1000
+	ConsoleTimeFormat  = "2006-01-02 15:04:05"
+)
+
+// 日志输出配置
+type SinkConfig struct {
+	Type    string // 输出类型：file、stdout、stderr、console、syslog、tcp、udp
+	Level   string // 该输出的最低日志等级，如 info、warn，为空时不单独过滤
+	LogPath string // file：日志目录，默认使用 LogConfig.LogPath
+	LogName string // file：日志文件名称，默认使用 LogConfig.LogName
+	NoColor bool   // console：是否禁用颜色
+	Network string // syslog：远程syslog的协议，默认：udp
+	Addr    string // syslog、tcp、udp：地址，如 127.0.0.1:5140，syslog为空时使用本机的syslog
+	Tag     string // syslog：标识，默认使用日志名称
+	Timeout int64  // tcp、udp：连接和写入的超时时间，单位：毫秒，默认：1000毫秒
+	// file：轮转配置，默认使用 LogConfig.Rotate
+	Rotate *RotateConfig
+}
+
+// 根据配置创建日志的输出
+// 配置了 Sinks 时输出到所有的 Sinks，否则 Console 为true时输出到控制台，都没有时输出到 LogPath/LogName 文件
+func newWriter(name string, conf LogConfig) (io.Writer, error) {
+	sinks := conf.Sinks
+	if len(sinks) == 0 {
+		if conf.Console {
+			sinks = []SinkConfig{{Type: SinkConsole}}
+		} else {
+			sinks = []SinkConfig{{Type: SinkFile}}
+		}
+	}
+	writers := make([]io.Writer, 0, len(sinks))
+	for _, sink := range sinks {
+		w, err := newSinkWriter(name, conf, sink)
+		if err != nil {
+			return nil, err
+		}
+		if sink.Level != "" {
+			level, err := zerolog.ParseLevel(sink.Level)
+			if err != nil {
+				return nil, err
+			}
+			w = &levelFilterWriter{w: toLevelWriter(w), level: level}
+		}
+		writers = append(writers, w)
+	}
+	if len(writers) == 1 {
+		return writers[0], nil
+	}
+	return zerolog.MultiLevelWriter(writers...), nil
+}
+
+// 创建单个输出
+func newSinkWriter(name string, conf LogConfig, sink SinkConfig) (io.Writer, error) {
+	switch sink.Type {
+	case SinkFile, "":
+		logPath, logName := sink.LogPath, sink.LogName
+		if logPath == "" {
+			logPath = conf.LogPath
+		}
+		if logName == "" {
+			logName = conf.LogName
+		}
+		rotate := conf.Rotate
+		if sink.Rotate != nil {
+			rotate = *sink.Rotate
+		}
+		return NewRotateWriter(path.Join(logPath, logName), rotate)
+	case SinkStdout:
+		return os.Stdout, nil
+	case SinkStderr:
+		return os.Stderr, nil
+	case SinkConsole:
+		return NewConsoleWriter(os.Stdout, sink.NoColor), nil
+	case SinkSyslog:
+		tag := sink.Tag
+		if tag == "" {
+			tag = name
+		}
+		return newSyslogWriter(sink.Network, sink.Addr, tag)
+	case SinkTCP, SinkUDP:
+		if sink.Addr == "" {
+			return nil, fmt.Errorf("log: %s sink of %s requires an address", sink.Type, name)
+		}
+		timeout := sink.Timeout
+		if timeout <= 0 {
+			timeout = DefaultSinkTimeout
+		}
+		return &netWriter{network: sink.Type, addr: sink.Addr, timeout: time.Duration(timeout) * time.Millisecond}, nil
+	}
+	return nil, fmt.Errorf("log: unknown sink type %q of %s", sink.Type, name)
+}
+
+// 带颜色的控制台输出，一般在开发环境使用
+func NewConsoleWriter(out io.Writer, noColor bool) zerolog.ConsoleWriter {
+	return zerolog.ConsoleWriter{Out: out, NoColor: noColor, TimeFormat: ConsoleTimeFormat}
+}
+
+func toLevelWriter(w io.Writer) zerolog.LevelWriter {
+	if lw, ok := w.(zerolog.LevelWriter); ok {
+		return lw
+	}
+	return zerolog.MultiLevelWriter(w)
+}
+
+// 按日志等级过滤的输出
+type levelFilterWriter struct {
+	w     zerolog.LevelWriter
+	level zerolog.Level
+}
+
+func (f *levelFilterWriter) Write(p []byte) (int, error) {
+	return f.w.Write(p)
+}
+
+func (f *levelFilterWriter) WriteLevel(l zerolog.Level, p []byte) (int, error) {
+	if l < f.level {
+		return len(p), nil
+	}
+	return f.w.WriteLevel(l, p)
+}
+
+// 连接失败后重新连接的等待时间，每次失败翻倍直到最大值
+const (
+	netSinkMinBackoff = time.Second
+	netSinkMaxBackoff = time.Minute
+)
+
+var errNetSinkBackoff = errors.New("log: sink is unavailable, waiting to reconnect")
+
+// 通过TCP或者UDP发送JSON日志到收集服务，连接断开后在下次写入时重新连接
+// 连接失败后等待一段时间再重新连接，等待期间的日志直接丢弃，避免每次写入都阻塞在连接超时上
+type netWriter struct {
+	network string
+	addr    string
+	timeout time.Duration
+
+	mu      sync.Mutex
+	conn    net.Conn
+	backoff time.Duration
+	retryAt time.Time
+}
+
+func (w *netWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conn == nil {
+		if time.Now().Before(w.retryAt) {
+			return 0, errNetSinkBackoff
+		}
+		conn, err := net.DialTimeout(w.network, w.addr, w.timeout)
+		if err != nil {
+			if w.backoff == 0 {
+				w.backoff = netSinkMinBackoff
+			} else if w.backoff < netSinkMaxBackoff {
+				w.backoff *= 2
+				if w.backoff > netSinkMaxBackoff {
+					w.backoff = netSinkMaxBackoff
+				}
+			}
+			w.retryAt = time.Now().Add(w.backoff)
+			return 0, err
+		}
+		w.conn = conn
+		w.backoff = 0
+	}
+	w.conn.SetWriteDeadline(time.Now().Add(w.timeout))
+	n, err := w.conn.Write(p)
+	if err != nil {
+		w.conn.Close()
+		w.conn = nil
+	}
+	return n, err
+}
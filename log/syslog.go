@@ -0,0 +1,28 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package log
+
+import (
+	"log/syslog"
+
+	"github.com/rs/zerolog"
+)
+
+// 输出到syslog，日志等级转换为syslog的等级
+func newSyslogWriter(network, addr, tag string) (zerolog.LevelWriter, error) {
+	var w *syslog.Writer
+	var err error
+	if addr == "" {
+		w, err = syslog.New(syslog.LOG_INFO|syslog.LOG_USER, tag)
+	} else {
+		if network == "" {
+			network = "udp"
+		}
+		w, err = syslog.Dial(network, addr, syslog.LOG_INFO|syslog.LOG_USER, tag)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return zerolog.SyslogLevelWriter(w), nil
+}
@@ -0,0 +1,14 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package log
+
+import (
+	"errors"
+
+	"github.com/rs/zerolog"
+)
+
+func newSyslogWriter(network, addr, tag string) (zerolog.LevelWriter, error) {
+	return nil, errors.New("log: syslog is not supported on this platform")
+}
@@ -0,0 +1,61 @@
+package rbac
+
+import (
+	"github.com/Mueat/frm-lib/db"
+)
+
+// 角色
+type Role struct {
+	db.Model
+	Name  string `gorm:"size:64;uniqueIndex" json:"name"` // 角色标识
+	Title string `gorm:"size:128" json:"title"`           // 角色名称
+}
+
+func (Role) TableName() string {
+	return "rbac_roles"
+}
+
+// 权限
+// 权限名称使用点号分隔，如 order.view，支持通配符，如 order.* 或 *
+type Permission struct {
+	db.Model
+	Name  string `gorm:"size:128;uniqueIndex" json:"name"` // 权限标识
+	Title string `gorm:"size:128" json:"title"`            // 权限名称
+}
+
+func (Permission) TableName() string {
+	return "rbac_permissions"
+}
+
+// 角色拥有的权限
+type RolePermission struct {
+	db.Model
+	RoleID       uint `gorm:"uniqueIndex:idx_rbac_role_permission" json:"role_id"`
+	PermissionID uint `gorm:"uniqueIndex:idx_rbac_role_permission" json:"permission_id"`
+}
+
+func (RolePermission) TableName() string {
+	return "rbac_role_permissions"
+}
+
+// 角色继承，RoleID 继承 ParentID 的全部权限
+type RoleParent struct {
+	db.Model
+	RoleID   uint `gorm:"uniqueIndex:idx_rbac_role_parent" json:"role_id"`
+	ParentID uint `gorm:"uniqueIndex:idx_rbac_role_parent" json:"parent_id"`
+}
+
+func (RoleParent) TableName() string {
+	return "rbac_role_parents"
+}
+
+// 用户拥有的角色
+type UserRole struct {
+	db.Model
+	UID    uint `gorm:"uniqueIndex:idx_rbac_user_role" json:"uid"`
+	RoleID uint `gorm:"uniqueIndex:idx_rbac_user_role" json:"role_id"`
+}
+
+func (UserRole) TableName() string {
+	return "rbac_user_roles"
+}
@@ -0,0 +1,367 @@
+package rbac
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Mueat/frm-lib/cache"
+	"github.com/Mueat/frm-lib/errors"
+	"github.com/Mueat/frm-lib/http"
+	"github.com/Mueat/frm-lib/log"
+	"gorm.io/gorm"
+)
+
+const (
+	ErrPack = "RBAC"
+
+	DefaultPrefix      = "rbac:"
+	DefaultCacheExpire = 600
+)
+
+// 配置
+type Config struct {
+	Prefix      string // 缓存key前缀，默认：rbac:
+	CacheExpire int64  // 用户权限缓存时间，单位：秒，默认：600秒
+}
+
+// 权限校验器
+type Enforcer struct {
+	conf  Config
+	db    *gorm.DB
+	store cache.Store
+}
+
+// 创建权限校验器
+// @param *gorm.DB conn 数据库连接
+// @param cache.Store store 缓存，为nil时不缓存
+// @param Config conf 配置
+func New(conn *gorm.DB, store cache.Store, conf Config) *Enforcer {
+	if conf.Prefix == "" {
+		conf.Prefix = DefaultPrefix
+	}
+	if conf.CacheExpire <= 0 {
+		conf.CacheExpire = DefaultCacheExpire
+	}
+	return &Enforcer{conf: conf, db: conn, store: store}
+}
+
+// 创建数据表
+func (e *Enforcer) AutoMigrate() error {
+	return e.db.AutoMigrate(&Role{}, &Permission{}, &RolePermission{}, &RoleParent{}, &UserRole{})
+}
+
+// 创建角色
+func (e *Enforcer) CreateRole(name, title string) (*Role, error) {
+	role := Role{Name: name, Title: title}
+	if err := e.db.Create(&role).Error; err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+// 删除角色，同时删除角色的权限、继承关系以及用户的角色
+func (e *Enforcer) DeleteRole(name string) error {
+	role, err := e.findRole(name)
+	if err != nil {
+		return err
+	}
+	err = e.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("role_id = ?", role.ID).Delete(&RolePermission{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("role_id = ? OR parent_id = ?", role.ID, role.ID).Delete(&RoleParent{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("role_id = ?", role.ID).Delete(&UserRole{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(role).Error
+	})
+	if err != nil {
+		return err
+	}
+	return e.Invalidate()
+}
+
+// 创建权限
+func (e *Enforcer) CreatePermission(name, title string) (*Permission, error) {
+	perm := Permission{Name: name, Title: title}
+	if err := e.db.Create(&perm).Error; err != nil {
+		return nil, err
+	}
+	return &perm, nil
+}
+
+// 给角色授权
+func (e *Enforcer) Grant(roleName string, permName string) error {
+	role, err := e.findRole(roleName)
+	if err != nil {
+		return err
+	}
+	perm := Permission{}
+	if err := e.db.Where("name = ?", permName).First(&perm).Error; err != nil {
+		return err
+	}
+	rp := RolePermission{RoleID: role.ID, PermissionID: perm.ID}
+	if err := e.db.Where(&rp).FirstOrCreate(&rp).Error; err != nil {
+		return err
+	}
+	return e.Invalidate()
+}
+
+// 收回角色的权限
+func (e *Enforcer) Revoke(roleName string, permName string) error {
+	role, err := e.findRole(roleName)
+	if err != nil {
+		return err
+	}
+	perm := Permission{}
+	if err := e.db.Where("name = ?", permName).First(&perm).Error; err != nil {
+		return err
+	}
+	if err := e.db.Where("role_id = ? AND permission_id = ?", role.ID, perm.ID).Delete(&RolePermission{}).Error; err != nil {
+		return err
+	}
+	return e.Invalidate()
+}
+
+// 设置角色继承，roleName 将拥有 parentName 的全部权限
+func (e *Enforcer) Inherit(roleName string, parentName string) error {
+	role, err := e.findRole(roleName)
+	if err != nil {
+		return err
+	}
+	parent, err := e.findRole(parentName)
+	if err != nil {
+		return err
+	}
+	ancestors, err := e.expandRoles([]uint{parent.ID})
+	if err != nil {
+		return err
+	}
+	for _, id := range ancestors {
+		if id == role.ID {
+			return fmt.Errorf("role %s can not inherit %s: circular inheritance", roleName, parentName)
+		}
+	}
+	rp := RoleParent{RoleID: role.ID, ParentID: parent.ID}
+	if err := e.db.Where(&rp).FirstOrCreate(&rp).Error; err != nil {
+		return err
+	}
+	return e.Invalidate()
+}
+
+// 取消角色继承
+func (e *Enforcer) Disinherit(roleName string, parentName string) error {
+	role, err := e.findRole(roleName)
+	if err != nil {
+		return err
+	}
+	parent, err := e.findRole(parentName)
+	if err != nil {
+		return err
+	}
+	if err := e.db.Where("role_id = ? AND parent_id = ?", role.ID, parent.ID).Delete(&RoleParent{}).Error; err != nil {
+		return err
+	}
+	return e.Invalidate()
+}
+
+// 给用户分配角色
+func (e *Enforcer) AssignRole(uid uint, roleName string) error {
+	role, err := e.findRole(roleName)
+	if err != nil {
+		return err
+	}
+	ur := UserRole{UID: uid, RoleID: role.ID}
+	if err := e.db.Where(&ur).FirstOrCreate(&ur).Error; err != nil {
+		return err
+	}
+	return e.invalidateUser(uid)
+}
+
+// 取消用户的角色
+func (e *Enforcer) UnassignRole(uid uint, roleName string) error {
+	role, err := e.findRole(roleName)
+	if err != nil {
+		return err
+	}
+	if err := e.db.Where("uid = ? AND role_id = ?", uid, role.ID).Delete(&UserRole{}).Error; err != nil {
+		return err
+	}
+	return e.invalidateUser(uid)
+}
+
+// 获取用户直接分配的角色
+func (e *Enforcer) UserRoles(uid uint) ([]Role, error) {
+	roles := make([]Role, 0)
+	err := e.db.Where("id IN (?)", e.db.Model(&UserRole{}).Select("role_id").Where("uid = ?", uid)).Find(&roles).Error
+	return roles, err
+}
+
+// 获取用户的全部权限，包含继承的权限
+func (e *Enforcer) UserPermissions(uid uint) ([]string, error) {
+	key := e.userKey(uid)
+	if e.store != nil {
+		if str := e.store.GetString(key); str != "" {
+			perms := make([]string, 0)
+			if err := json.Unmarshal([]byte(str), &perms); err == nil {
+				return perms, nil
+			}
+		}
+	}
+
+	roleIDs := make([]uint, 0)
+	if err := e.db.Model(&UserRole{}).Where("uid = ?", uid).Pluck("role_id", &roleIDs).Error; err != nil {
+		return nil, err
+	}
+	roleIDs, err := e.expandRoles(roleIDs)
+	if err != nil {
+		return nil, err
+	}
+	perms := make([]string, 0)
+	if len(roleIDs) > 0 {
+		sub := e.db.Model(&RolePermission{}).Select("permission_id").Where("role_id IN ?", roleIDs)
+		if err := e.db.Model(&Permission{}).Where("id IN (?)", sub).Pluck("name", &perms).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	if e.store != nil {
+		if b, err := json.Marshal(perms); err == nil {
+			_ = e.store.Set(key, string(b), time.Duration(e.conf.CacheExpire)*time.Second)
+		}
+	}
+	return perms, nil
+}
+
+// 判断用户是否拥有权限
+func (e *Enforcer) Can(uid uint, perm string) (bool, error) {
+	perms, err := e.UserPermissions(uid)
+	if err != nil {
+		return false, err
+	}
+	for _, p := range perms {
+		if Match(p, perm) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// 清除全部用户的权限缓存
+func (e *Enforcer) Invalidate() error {
+	if e.store == nil {
+		return nil
+	}
+	_, err := e.store.Incr(e.conf.Prefix + "version")
+	return err
+}
+
+// 清除用户的权限缓存
+func (e *Enforcer) invalidateUser(uid uint) error {
+	if e.store == nil {
+		return nil
+	}
+	return e.store.Del(e.userKey(uid))
+}
+
+// 用户权限缓存key，包含版本号，角色或权限变化时只需要修改版本号
+func (e *Enforcer) userKey(uid uint) string {
+	version := "0"
+	if e.store != nil {
+		if v := e.store.GetString(e.conf.Prefix + "version"); v != "" {
+			version = v
+		}
+	}
+	return e.conf.Prefix + "user:" + version + ":" + strconv.FormatUint(uint64(uid), 10)
+}
+
+func (e *Enforcer) findRole(name string) (*Role, error) {
+	role := Role{}
+	if err := e.db.Where("name = ?", name).First(&role).Error; err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+// 获取角色以及全部祖先角色
+func (e *Enforcer) expandRoles(roleIDs []uint) ([]uint, error) {
+	visited := make(map[uint]bool)
+	result := make([]uint, 0)
+	queue := roleIDs
+	for len(queue) > 0 {
+		next := make([]uint, 0)
+		for _, id := range queue {
+			if !visited[id] {
+				visited[id] = true
+				result = append(result, id)
+				next = append(next, id)
+			}
+		}
+		if len(next) == 0 {
+			break
+		}
+		parents := make([]uint, 0)
+		if err := e.db.Model(&RoleParent{}).Where("role_id IN ?", next).Pluck("parent_id", &parents).Error; err != nil {
+			return nil, err
+		}
+		queue = parents
+	}
+	return result, nil
+}
+
+// 判断拥有的权限是否匹配需要的权限
+// 支持通配符：* 匹配全部权限，order.* 匹配 order.view、order.item.edit 等
+func Match(granted string, required string) bool {
+	if granted == "*" || granted == required {
+		return true
+	}
+	if strings.HasSuffix(granted, ".*") {
+		return strings.HasPrefix(required, granted[:len(granted)-1])
+	}
+	return false
+}
+
+// 权限校验中间件
+// 校验路由通过 Router.Perm、GinServer.Perm 或 @perm 注解声明的权限，未声明权限的路由不做校验
+// @param func(app *http.App) uint uidFunc 获取当前用户ID的方法，返回0表示未登录
+func (e *Enforcer) Middleware(uidFunc func(app *http.App) uint) http.RouterFun {
+	return func(app *http.App) {
+		perm := app.RoutePerm()
+		if perm == "" {
+			return
+		}
+		e.check(app, uidFunc, perm)
+	}
+}
+
+// 校验指定权限的中间件，用于单个路由或路由组
+func (e *Enforcer) Require(uidFunc func(app *http.App) uint, perm string) http.RouterFun {
+	return func(app *http.App) {
+		e.check(app, uidFunc, perm)
+	}
+}
+
+func (e *Enforcer) check(app *http.App, uidFunc func(app *http.App) uint, perm string) {
+	uid := uidFunc(app)
+	if uid == 0 {
+		app.Error(errors.Unauthorized)
+		app.Abort()
+		return
+	}
+	ok, err := e.Can(uid, perm)
+	if err != nil {
+		log.Error().Err(err).Str("type", ErrPack).Str("name", "rbac").Str("method", "Can").Uint("uid", uid).Str("perm", perm).Send()
+		app.Error(errors.InternalServerError)
+		app.Abort()
+		return
+	}
+	if !ok {
+		app.Error(errors.Forbidden)
+		app.Abort()
+	}
+}
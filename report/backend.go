@@ -0,0 +1,89 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sync"
+
+	"github.com/Mueat/frm-lib/curl"
+)
+
+// 保存到本地文件，每行一个JSON格式的错误
+type FileBackend struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// 创建文件后端，文件不存在时自动创建
+func NewFileBackend(file string) (*FileBackend, error) {
+	if err := os.MkdirAll(path.Dir(file), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileBackend{file: f}, nil
+}
+
+func (b *FileBackend) Send(ev *Event) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	_, err = b.file.Write(append(data, '\n'))
+	return err
+}
+
+// 关闭文件
+func (b *FileBackend) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.file.Close()
+}
+
+// 通过HTTP POST发送JSON格式的错误，可以对接自建的错误收集服务
+type HTTPBackend struct {
+	URL     string            // 接收地址
+	Headers map[string]string // 附加的请求头，如鉴权信息
+	Timeout int64             // 请求超时时间，单位：秒，默认：5秒
+
+	client lazyClient
+}
+
+func (b *HTTPBackend) Send(ev *Event) error {
+	headers := map[string]string{"Content-Type": "application/json"}
+	for k, v := range b.Headers {
+		headers[k] = v
+	}
+	resp, err := b.client.get(b.Timeout).Do("POST", b.URL, ev, headers)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("report backend %s responded with status %d", b.URL, resp.StatusCode)
+	}
+	return nil
+}
+
+// 第一次使用时创建的HTTP客户端，同一个后端或通知的请求复用同一个客户端
+type lazyClient struct {
+	once   sync.Once
+	client *curl.Client
+}
+
+// 获取客户端，超时时间只在创建时生效
+func (c *lazyClient) get(timeout int64) *curl.Client {
+	c.once.Do(func() {
+		if timeout <= 0 {
+			timeout = 5
+		}
+		c.client = curl.New(curl.Opts{Timeout: timeout})
+	})
+	return c.client
+}
@@ -0,0 +1,124 @@
+package report
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Mueat/frm-lib/curl"
+)
+
+// 机器人接口的返回
+type robotResponse struct {
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
+
+// 企业微信群机器人通知
+type WeComNotifier struct {
+	Webhook string // 机器人的webhook地址
+	Timeout int64  // 请求超时时间，单位：秒，默认：5秒
+
+	client lazyClient
+}
+
+func (n *WeComNotifier) Notify(ev *Event) error {
+	data := map[string]interface{}{
+		"msgtype": "markdown",
+		"markdown": map[string]string{
+			"content": markdownText(ev),
+		},
+	}
+	return sendRobot(n.client.get(n.Timeout), n.Webhook, data)
+}
+
+// 钉钉群机器人通知
+type DingTalkNotifier struct {
+	Webhook string // 机器人的webhook地址
+	Secret  string // 加签的秘钥，未开启加签时为空
+	Timeout int64  // 请求超时时间，单位：秒，默认：5秒
+
+	client lazyClient
+}
+
+func (n *DingTalkNotifier) Notify(ev *Event) error {
+	webhook := n.Webhook
+	if n.Secret != "" {
+		timestamp := strconv.FormatInt(time.Now().UnixNano()/int64(time.Millisecond), 10)
+		mac := hmac.New(sha256.New, []byte(n.Secret))
+		mac.Write([]byte(timestamp + "\n" + n.Secret))
+		sign := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+		sep := "?"
+		if strings.Contains(webhook, "?") {
+			sep = "&"
+		}
+		webhook += sep + "timestamp=" + timestamp + "&sign=" + url.QueryEscape(sign)
+	}
+	data := map[string]interface{}{
+		"msgtype": "markdown",
+		"markdown": map[string]string{
+			"title": title(ev),
+			"text":  markdownText(ev),
+		},
+	}
+	return sendRobot(n.client.get(n.Timeout), webhook, data)
+}
+
+// 发送机器人消息
+func sendRobot(client *curl.Client, webhook string, data interface{}) error {
+	resp, err := client.Do("POST", webhook, data, map[string]string{"Content-Type": "application/json"})
+	if err != nil {
+		return err
+	}
+	res := robotResponse{}
+	if err := curl.BindResponse(resp, &res); err != nil {
+		return err
+	}
+	if res.ErrCode != 0 {
+		return fmt.Errorf("robot responded with %d: %s", res.ErrCode, res.ErrMsg)
+	}
+	return nil
+}
+
+// 通知的标题
+func title(ev *Event) string {
+	s := "[" + ev.Level + "]"
+	if ev.Server != "" {
+		s += " " + ev.Server
+	}
+	if ev.Environment != "" {
+		s += " (" + ev.Environment + ")"
+	}
+	return s
+}
+
+// 通知的内容
+func markdownText(ev *Event) string {
+	var b strings.Builder
+	b.WriteString("### " + title(ev) + "\n")
+	b.WriteString("> " + ev.Message + "\n\n")
+	if ev.Code != 0 {
+		b.WriteString("- code: " + strconv.Itoa(ev.Code) + "\n")
+	}
+	if ev.Cause != "" {
+		b.WriteString("- cause: " + ev.Cause + "\n")
+	}
+	b.WriteString("- location: " + ev.Func + " " + ev.File + ":" + strconv.Itoa(ev.Line) + "\n")
+	if ev.RequestID != "" {
+		b.WriteString("- request_id: " + ev.RequestID + "\n")
+	}
+	if ev.Count > 1 {
+		b.WriteString(fmt.Sprintf("- repeated: %d times since %s\n", ev.Count, ev.FirstSeen.Format("2006-01-02 15:04:05")))
+	}
+	if ev.Suppressed > 0 {
+		b.WriteString(fmt.Sprintf("- suppressed: %d notifications\n", ev.Suppressed))
+	}
+	b.WriteString("- time: " + ev.Time.Format("2006-01-02 15:04:05") + "\n")
+	b.WriteString("- fingerprint: " + ev.Fingerprint + "\n")
+	return b.String()
+}
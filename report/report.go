@@ -0,0 +1,356 @@
+package report
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Mueat/frm-lib/errors"
+	"github.com/Mueat/frm-lib/log"
+	"github.com/Mueat/frm-lib/trace"
+)
+
+const (
+	ErrPack = "REPORT"
+
+	LevelError = "error"
+	LevelPanic = "panic"
+
+	DefaultWindow      = 60
+	DefaultNotifyLimit = 20
+	DefaultQueueSize   = 1000
+)
+
+// 上报配置
+type Config struct {
+	Environment string     // 环境，会附加到上报的错误中
+	Server      string     // 服务名称，会附加到上报的错误中
+	Window      int64      // 相同错误的去重时间窗口，单位：秒，默认：60秒
+	NotifyLimit int        // 每分钟最多发送的通知数，超过的通知会被丢弃并在下一条通知中提示，默认：20
+	QueueSize   int        // 待上报队列长度，队列满时丢弃，默认：1000
+	Codes       []int      // 需要上报的错误码，默认：errors.System、errors.InternalServerError
+	Backends    []Backend  // 保存错误的后端，如 FileBackend、HTTPBackend
+	Notifiers   []Notifier // 通知，如 WeComNotifier、DingTalkNotifier
+}
+
+// 上报的错误
+type Event struct {
+	Fingerprint string                 `json:"fingerprint"`
+	Level       string                 `json:"level"`
+	Code        int                    `json:"code,omitempty"`
+	Message     string                 `json:"message"`
+	Cause       string                 `json:"cause,omitempty"`
+	File        string                 `json:"file"`
+	Func        string                 `json:"func"`
+	Line        int                    `json:"line"`
+	Stack       string                 `json:"stack,omitempty"`
+	Fields      map[string]interface{} `json:"fields,omitempty"`
+	RequestID   string                 `json:"request_id,omitempty"`
+	Environment string                 `json:"environment,omitempty"`
+	Server      string                 `json:"server,omitempty"`
+	Count       int                    `json:"count"`                // 该事件代表的发生次数，时间窗口内重复的错误会合并为一个事件
+	Suppressed  int                    `json:"suppressed,omitempty"` // 因为限流被丢弃的通知数
+	FirstSeen   time.Time              `json:"first_seen"`
+	Time        time.Time              `json:"time"`
+}
+
+// 保存错误的后端
+type Backend interface {
+	Send(ev *Event) error
+}
+
+// 错误通知
+type Notifier interface {
+	Notify(ev *Event) error
+}
+
+// 时间窗口内的错误
+type window struct {
+	start       time.Time
+	count       int // 时间窗口内的总次数
+	repeatFirst time.Time
+	last        *Event // 最后一次的错误
+}
+
+// 错误上报，错误按指纹去重后异步发送到后端和通知
+type Reporter struct {
+	conf   Config
+	codes  map[int]bool
+	queue  chan *Event
+	done   chan struct{}
+	once   sync.Once
+	mu     sync.RWMutex
+	closed bool
+
+	// 以下字段只在上报协程中使用
+	windows     map[string]*window
+	notifyStart time.Time
+	notified    int
+	suppressed  int
+}
+
+// 创建错误上报
+func New(conf Config) *Reporter {
+	if conf.Window <= 0 {
+		conf.Window = DefaultWindow
+	}
+	if conf.NotifyLimit <= 0 {
+		conf.NotifyLimit = DefaultNotifyLimit
+	}
+	if conf.QueueSize <= 0 {
+		conf.QueueSize = DefaultQueueSize
+	}
+	if len(conf.Codes) == 0 {
+		conf.Codes = []int{errors.System, errors.InternalServerError}
+	}
+	r := &Reporter{
+		conf:    conf,
+		codes:   make(map[int]bool, len(conf.Codes)),
+		queue:   make(chan *Event, conf.QueueSize),
+		done:    make(chan struct{}),
+		windows: make(map[string]*window),
+	}
+	for _, code := range conf.Codes {
+		r.codes[code] = true
+	}
+	go r.run()
+	return r
+}
+
+// 错误码是否需要上报
+func (r *Reporter) Reportable(code int) bool {
+	return r.codes[code]
+}
+
+// 上报错误，普通错误通过 errors.From 转换，错误码不在 Config.Codes 中时不上报
+// @param context.Context ctx 请求的context，用于获取请求ID
+// @param error err 错误
+// @param map[string]interface{} extra 附加信息，如请求地址
+func (r *Reporter) Capture(ctx context.Context, err error, extra map[string]interface{}) {
+	e := errors.From(err)
+	if e == nil || !r.Reportable(e.Code) {
+		return
+	}
+	ev := &Event{
+		Level:   LevelError,
+		Code:    e.Code,
+		Message: e.Msg,
+		File:    e.File,
+		Func:    e.Func,
+		Line:    e.Line,
+		Stack:   e.StackTrace(),
+		Fields:  mergeFields(e.Fields, extra),
+	}
+	if cause := e.Cause(); cause != nil {
+		ev.Cause = cause.Error()
+	}
+	r.enqueue(ctx, ev)
+}
+
+// 上报panic，需要在recover的defer方法中调用，panic的位置从调用栈中获取
+//
+//	defer func() {
+//		if v := recover(); v != nil {
+//			reporter.CapturePanic(ctx, v, nil)
+//		}
+//	}()
+func (r *Reporter) CapturePanic(ctx context.Context, v interface{}, extra map[string]interface{}) {
+	ev := &Event{
+		Level:   LevelPanic,
+		Code:    errors.InternalServerError,
+		Message: fmt.Sprint(v),
+		Fields:  mergeFields(nil, extra),
+	}
+	if err, ok := v.(error); ok {
+		ev.Message = err.Error()
+	}
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(2, pcs)
+	ev.File, ev.Func, ev.Line, ev.Stack = panicFrames(pcs[:n])
+	r.enqueue(ctx, ev)
+}
+
+// 加入上报队列，队列满时丢弃
+func (r *Reporter) enqueue(ctx context.Context, ev *Event) {
+	now := time.Now()
+	ev.Fingerprint = Fingerprint(ev.File, ev.Func, ev.Line)
+	if ctx != nil {
+		ev.RequestID = trace.FromContext(ctx)
+	}
+	ev.Environment = r.conf.Environment
+	ev.Server = r.conf.Server
+	ev.Count = 1
+	ev.FirstSeen = now
+	ev.Time = now
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.closed {
+		return
+	}
+	select {
+	case r.queue <- ev:
+	default:
+		log.Warn().Str("type", ErrPack).Str("name", "reporter").Str("method", "enqueue").Str("fingerprint", ev.Fingerprint).Msg("report queue is full")
+	}
+}
+
+// 关闭上报，发送队列中剩余的错误以及时间窗口内合并的错误
+func (r *Reporter) Close() {
+	r.once.Do(func() {
+		r.mu.Lock()
+		r.closed = true
+		close(r.queue)
+		r.mu.Unlock()
+		<-r.done
+	})
+}
+
+// 上报协程
+func (r *Reporter) run() {
+	defer close(r.done)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case ev, ok := <-r.queue:
+			if !ok {
+				for fp, w := range r.windows {
+					r.flush(fp, w)
+				}
+				return
+			}
+			r.handle(ev)
+		case now := <-ticker.C:
+			r.expire(now)
+		}
+	}
+}
+
+// 处理错误，时间窗口内第一次出现时立即发送，之后只计数
+func (r *Reporter) handle(ev *Event) {
+	w, ok := r.windows[ev.Fingerprint]
+	if ok && ev.Time.Sub(w.start) < r.windowDuration() {
+		w.count++
+		if w.count == 2 {
+			w.repeatFirst = ev.Time
+		}
+		w.last = ev
+		return
+	}
+	if ok {
+		r.flush(ev.Fingerprint, w)
+	}
+	r.windows[ev.Fingerprint] = &window{start: ev.Time, count: 1, last: ev}
+	r.dispatch(ev)
+}
+
+// 结束过期的时间窗口
+func (r *Reporter) expire(now time.Time) {
+	for fp, w := range r.windows {
+		if now.Sub(w.start) >= r.windowDuration() {
+			r.flush(fp, w)
+		}
+	}
+}
+
+// 结束时间窗口，有重复的错误时合并为一个事件发送
+func (r *Reporter) flush(fp string, w *window) {
+	delete(r.windows, fp)
+	if w.count <= 1 {
+		return
+	}
+	ev := *w.last
+	ev.Count = w.count - 1
+	ev.FirstSeen = w.repeatFirst
+	r.dispatch(&ev)
+}
+
+func (r *Reporter) windowDuration() time.Duration {
+	return time.Duration(r.conf.Window) * time.Second
+}
+
+// 发送到后端和通知
+func (r *Reporter) dispatch(ev *Event) {
+	for _, b := range r.conf.Backends {
+		if err := b.Send(ev); err != nil {
+			log.Error().Err(err).Str("type", ErrPack).Str("name", "reporter").Str("method", "Send").Str("fingerprint", ev.Fingerprint).Send()
+		}
+	}
+	if len(r.conf.Notifiers) == 0 {
+		return
+	}
+
+	// 每分钟最多发送 NotifyLimit 条通知
+	if ev.Time.Sub(r.notifyStart) >= time.Minute {
+		r.notifyStart = ev.Time
+		r.notified = 0
+	}
+	if r.notified >= r.conf.NotifyLimit {
+		r.suppressed++
+		return
+	}
+	r.notified++
+	nev := *ev
+	nev.Suppressed = r.suppressed
+	r.suppressed = 0
+	for _, n := range r.conf.Notifiers {
+		if err := n.Notify(&nev); err != nil {
+			log.Error().Err(err).Str("type", ErrPack).Str("name", "reporter").Str("method", "Notify").Str("fingerprint", ev.Fingerprint).Send()
+		}
+	}
+}
+
+// 根据错误的位置生成指纹
+func Fingerprint(file, fn string, line int) string {
+	h := sha1.Sum([]byte(file + "\x00" + fn + "\x00" + strconv.Itoa(line)))
+	return hex.EncodeToString(h[:])
+}
+
+// 合并附加信息
+func mergeFields(fields, extra map[string]interface{}) map[string]interface{} {
+	if len(fields) == 0 && len(extra) == 0 {
+		return nil
+	}
+	res := make(map[string]interface{}, len(fields)+len(extra))
+	for k, v := range fields {
+		res[k] = v
+	}
+	for k, v := range extra {
+		res[k] = v
+	}
+	return res
+}
+
+// 从recover时的调用栈中获取panic的位置和调用栈，不在panic中时使用调用者的位置
+func panicFrames(pcs []uintptr) (file, fn string, line int, stack string) {
+	frames := make([]runtime.Frame, 0, len(pcs))
+	iter := runtime.CallersFrames(pcs)
+	start := 0
+	for {
+		frame, more := iter.Next()
+		frames = append(frames, frame)
+		if frame.Function == "runtime.gopanic" {
+			start = len(frames)
+		}
+		if !more {
+			break
+		}
+	}
+	// 跳过运行时触发panic的方法，如 runtime.panicmem
+	for start < len(frames)-1 && strings.HasPrefix(frames[start].Function, "runtime.") {
+		start++
+	}
+	var b strings.Builder
+	for _, frame := range frames[start:] {
+		b.WriteString(frame.Function + "\n\t" + frame.File + ":" + strconv.Itoa(frame.Line) + "\n")
+	}
+	first := frames[start]
+	return first.File, first.Function, first.Line, b.String()
+}
@@ -0,0 +1,194 @@
+package report
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Mueat/frm-lib/errors"
+)
+
+// 记录收到的错误
+type recorder struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (r *recorder) Send(ev *Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, *ev)
+	return nil
+}
+
+func (r *recorder) Notify(ev *Event) error {
+	return r.Send(ev)
+}
+
+func (r *recorder) list() []Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Event(nil), r.events...)
+}
+
+// 创建不启动上报协程的Reporter，由测试直接调用 handle 和 expire
+func newTestReporter(conf Config) *Reporter {
+	return &Reporter{conf: conf, windows: make(map[string]*window)}
+}
+
+func testEvent(line int, t time.Time) *Event {
+	return &Event{
+		Fingerprint: Fingerprint("a.go", "main.a", line),
+		Line:        line,
+		Count:       1,
+		FirstSeen:   t,
+		Time:        t,
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	fp := Fingerprint("a.go", "main.a", 1)
+	if fp != Fingerprint("a.go", "main.a", 1) {
+		t.Fatal("fingerprint of the same location should be stable")
+	}
+	for _, other := range []string{
+		Fingerprint("a.go", "main.a", 2),
+		Fingerprint("b.go", "main.a", 1),
+		Fingerprint("a.go", "main.b", 1),
+		Fingerprint("a.go1", "main.a", 1),
+	} {
+		if other == fp {
+			t.Fatal("fingerprint of different locations should differ")
+		}
+	}
+}
+
+func TestCaptureDedup(t *testing.T) {
+	rec := &recorder{}
+	r := New(Config{Backends: []Backend{rec}, Server: "api"})
+	for i := 0; i < 3; i++ {
+		r.Capture(context.Background(), errors.Code(errors.System), nil)
+	}
+	r.Capture(context.Background(), errors.Code(errors.System), map[string]interface{}{"url": "/a"})
+	// 不在 Codes 中的错误不上报
+	r.Capture(context.Background(), errors.Code(errors.Params), nil)
+	r.Close()
+
+	events := rec.list()
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events, got %+v", events)
+	}
+	first, other, merged := events[0], events[1], events[2]
+	if first.Fingerprint == other.Fingerprint {
+		t.Fatal("errors from different lines should not be merged")
+	}
+	if first.Count != 1 || first.Server != "api" || first.Code != errors.System {
+		t.Fatalf("unexpected first event: %+v", first)
+	}
+	if other.Fields["url"] != "/a" {
+		t.Fatalf("unexpected fields: %+v", other.Fields)
+	}
+	// 时间窗口内重复的2次合并为一个事件
+	if merged.Fingerprint != first.Fingerprint || merged.Count != 2 {
+		t.Fatalf("unexpected merged event: %+v", merged)
+	}
+}
+
+func TestReportWindow(t *testing.T) {
+	rec := &recorder{}
+	r := newTestReporter(Config{Window: 60, NotifyLimit: 20, Backends: []Backend{rec}})
+	start := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+	r.handle(testEvent(1, start))
+	r.handle(testEvent(1, start.Add(10*time.Second)))
+	r.handle(testEvent(1, start.Add(20*time.Second)))
+	if n := len(rec.list()); n != 1 {
+		t.Fatalf("repeated errors should be counted only, got %d events", n)
+	}
+
+	// 时间窗口结束时发送合并的事件
+	r.expire(start.Add(59 * time.Second))
+	if n := len(rec.list()); n != 1 {
+		t.Fatalf("window should not expire yet, got %d events", n)
+	}
+	r.expire(start.Add(60 * time.Second))
+	events := rec.list()
+	if len(events) != 2 {
+		t.Fatalf("expected the merged event, got %+v", events)
+	}
+	if ev := events[1]; ev.Count != 2 || !ev.FirstSeen.Equal(start.Add(10*time.Second)) || !ev.Time.Equal(start.Add(20*time.Second)) {
+		t.Fatalf("unexpected merged event: %+v", ev)
+	}
+
+	// 没有重复时不发送合并的事件，新的时间窗口重新发送
+	r.handle(testEvent(1, start.Add(61*time.Second)))
+	r.expire(start.Add(121 * time.Second))
+	r.handle(testEvent(1, start.Add(122*time.Second)))
+	if n := len(rec.list()); n != 4 {
+		t.Fatalf("expected 4 events, got %d", n)
+	}
+}
+
+func TestReportNotifyLimit(t *testing.T) {
+	backend := &recorder{}
+	notifier := &recorder{}
+	r := newTestReporter(Config{Window: 60, NotifyLimit: 2, Backends: []Backend{backend}, Notifiers: []Notifier{notifier}})
+	start := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		r.handle(testEvent(i, start.Add(time.Duration(i)*time.Second)))
+	}
+	if n := len(backend.list()); n != 5 {
+		t.Fatalf("backends should not be throttled, got %d events", n)
+	}
+	if n := len(notifier.list()); n != 2 {
+		t.Fatalf("expected 2 notifications, got %d", n)
+	}
+
+	// 下一分钟的第一条通知带上被丢弃的数量
+	r.handle(testEvent(10, start.Add(time.Minute)))
+	r.handle(testEvent(11, start.Add(time.Minute+time.Second)))
+	events := notifier.list()
+	if len(events) != 4 {
+		t.Fatalf("expected 4 notifications, got %d", len(events))
+	}
+	if events[2].Suppressed != 3 || events[3].Suppressed != 0 {
+		t.Fatalf("unexpected suppressed counts: %d %d", events[2].Suppressed, events[3].Suppressed)
+	}
+	if events[0].Suppressed != 0 || events[1].Suppressed != 0 {
+		t.Fatal("notifications within the limit should not report suppressed ones")
+	}
+}
+
+func TestHTTPBackend(t *testing.T) {
+	var count int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&count, 1)
+		if req.Header.Get("X-Token") != "t" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer srv.Close()
+
+	b := &HTTPBackend{URL: srv.URL, Headers: map[string]string{"X-Token": "t"}}
+	if err := b.Send(testEvent(1, time.Now())); err != nil {
+		t.Fatal(err)
+	}
+	client := b.client.client
+	if err := b.Send(testEvent(2, time.Now())); err != nil {
+		t.Fatal(err)
+	}
+	if b.client.client != client {
+		t.Fatal("the client should be created once per backend")
+	}
+	if atomic.LoadInt32(&count) != 2 {
+		t.Fatalf("expected 2 requests, got %d", count)
+	}
+
+	b = &HTTPBackend{URL: srv.URL}
+	if err := b.Send(testEvent(1, time.Now())); err == nil {
+		t.Fatal("expected an error for a rejected request")
+	}
+}
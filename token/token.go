@@ -0,0 +1,284 @@
+package token
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Mueat/frm-lib/cache"
+	"github.com/Mueat/frm-lib/errors"
+	"github.com/Mueat/frm-lib/log"
+	"github.com/Mueat/frm-lib/util"
+)
+
+const (
+	ErrPack = "TOKEN"
+
+	// 刷新token的aud后缀，避免刷新token被当做访问token使用
+	RefreshAudSuffix = ":REFRESH"
+
+	DefaultAccessExpire  = 7200
+	DefaultRefreshExpire = 30 * 86400
+	DefaultPrefix        = "token:"
+)
+
+// token服务配置
+type Config struct {
+	Secret        string // 加密秘钥
+	Aud           string // 访问token的接收对象
+	AccessExpire  int64  // 访问token有效期，单位：秒，默认：7200秒
+	RefreshExpire int64  // 刷新token有效期，单位：秒，默认：30天
+	RefreshBindIP bool   // 刷新token是否校验IP
+	Prefix        string // 存储key的前缀，默认：token:
+}
+
+// 访问token和刷新token
+type Pair struct {
+	AccessToken      string `json:"access_token"`
+	RefreshToken     string `json:"refresh_token"`
+	ExpiresIn        int64  `json:"expires_in"`
+	RefreshExpiresIn int64  `json:"refresh_expires_in"`
+}
+
+// token服务
+// 在JWT的基础上提供刷新token轮换、重复使用检测以及服务端吊销
+type Service struct {
+	conf       Config
+	store      cache.Store
+	registered bool
+}
+
+// 创建token服务
+// @param Config conf 配置
+// @param cache.Store store 存储，一般为 cache.GetRedis 返回的连接，测试时可以使用 cache.NewMemory()
+func New(conf Config, store cache.Store) *Service {
+	if conf.AccessExpire <= 0 {
+		conf.AccessExpire = DefaultAccessExpire
+	}
+	if conf.RefreshExpire <= 0 {
+		conf.RefreshExpire = DefaultRefreshExpire
+	}
+	if conf.Prefix == "" {
+		conf.Prefix = DefaultPrefix
+	}
+	return &Service{conf: conf, store: store}
+}
+
+// 注册到 util.VerifyJWT，使所有通过 VerifyJWT 校验的该服务签发的token都会检查是否被吊销
+func (s *Service) Register() {
+	s.registered = true
+	util.AddJWTChecker(s.Check)
+}
+
+// 签发token
+// @param uint uid 用户ID
+// @param string ip 客户端IP
+// @param interface{} extra 额外数据
+func (s *Service) Issue(uid uint, ip string, extra interface{}) *Pair {
+	fid := util.Uniqid("tf")
+	if nonce, err := util.GenerateNonce(8); err == nil {
+		fid += nonce
+	}
+	return s.issue(uid, ip, fid, extra)
+}
+
+func (s *Service) issue(uid uint, ip string, fid string, extra interface{}) *Pair {
+	access := util.GetJWT(util.JWTReq{
+		Secret: s.conf.Secret,
+		Expire: s.conf.AccessExpire,
+		UID:    uid,
+		Aud:    s.conf.Aud,
+		IP:     ip,
+		Fid:    fid,
+		Extra:  extra,
+	})
+	refresh := util.GetJWT(util.JWTReq{
+		Secret: s.conf.Secret,
+		Expire: s.conf.RefreshExpire,
+		UID:    uid,
+		Aud:    s.refreshAud(),
+		IP:     ip,
+		Fid:    fid,
+		Extra:  extra,
+	})
+	return &Pair{
+		AccessToken:      access,
+		RefreshToken:     refresh,
+		ExpiresIn:        s.conf.AccessExpire,
+		RefreshExpiresIn: s.conf.RefreshExpire,
+	}
+}
+
+// 使用刷新token换取新的token
+// 刷新token只能使用一次，重复使用时视为token泄露，整个token家族都会被吊销
+// @param string refreshToken 刷新token
+// @param string ip 客户端IP
+func (s *Service) Refresh(refreshToken string, ip string) (*Pair, *errors.Err) {
+	tk, err := s.parse(refreshToken, s.refreshAud())
+	if err != nil {
+		return nil, err
+	}
+	if s.conf.RefreshBindIP && tk.IP != ip {
+		return nil, errors.CodeMsg(errors.Unauthorized, "token ip error")
+	}
+	if err := s.Check(tk); err != nil {
+		return nil, errors.CodeMsg(errors.Unauthorized, err.Error())
+	}
+
+	ok, er := s.store.SetNXEX(s.key("used", tk.Jti), strconv.FormatInt(time.Now().Unix(), 10), s.ttl(tk.Exp))
+	if er != nil {
+		return nil, errors.New(er)
+	}
+	if !ok {
+		log.Warn().Str("type", ErrPack).Str("name", "token").Str("method", "Refresh").Uint("uid", tk.UID).Str("fid", tk.Fid).Msg("refresh token reused, revoke family")
+		if er := s.RevokeFamily(tk.Fid); er != nil {
+			return nil, errors.New(er)
+		}
+		return nil, errors.CodeMsg(errors.Unauthorized, "token reused")
+	}
+
+	return s.issue(tk.UID, ip, tk.Fid, tk.Extra), nil
+}
+
+// 校验访问token
+// @param string token 访问token
+// @param string ip 客户端IP
+func (s *Service) Verify(token string, ip string) (*util.JWT, *errors.Err) {
+	tk, err := util.VerifyJWT(s.conf.Secret, token, s.conf.Aud, ip)
+	if err != nil {
+		return nil, errors.CodeMsg(errors.Unauthorized, err.Error())
+	}
+	// 未注册到VerifyJWT时需要单独检查吊销状态
+	if !s.registered {
+		if err := s.Check(tk); err != nil {
+			return nil, errors.CodeMsg(errors.Unauthorized, err.Error())
+		}
+	}
+	return tk, nil
+}
+
+// 检查token是否被吊销，只检查该服务签发的token，其他aud的token直接通过
+func (s *Service) Check(tk *util.JWT) error {
+	if tk.Aud != s.conf.Aud && tk.Aud != s.refreshAud() {
+		return nil
+	}
+	if tk.Jti != "" {
+		if ok, err := s.store.Exists(s.key("revoked", tk.Jti)); err != nil {
+			return err
+		} else if ok {
+			return fmt.Errorf("token revoked")
+		}
+	}
+	if tk.Fid != "" {
+		if ok, err := s.store.Exists(s.key("family", tk.Fid)); err != nil {
+			return err
+		} else if ok {
+			return fmt.Errorf("token revoked")
+		}
+	}
+	v, err := s.store.Get(s.userKey(tk.UID))
+	if err != nil {
+		return err
+	}
+	if v != "" {
+		revokedAt, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return err
+		}
+		// 旧的吊销记录单位为秒
+		if revokedAt < 1e12 {
+			revokedAt = (revokedAt+1)*int64(time.Second) - 1
+		}
+		// 旧的token没有纳秒的创建时间
+		iat := tk.IatNs
+		if iat == 0 {
+			iat = tk.Iat * int64(time.Second)
+		}
+		if iat <= revokedAt {
+			return fmt.Errorf("token revoked")
+		}
+	}
+	return nil
+}
+
+// 吊销token，访问token和刷新token都可以
+// @param string token 要吊销的token
+func (s *Service) RevokeToken(token string) error {
+	tk := util.JWT{}
+	if err := util.DecryptJWT(token, s.conf.Secret, &tk); err != nil {
+		return err
+	}
+	return s.Revoke(tk.Jti, tk.Exp)
+}
+
+// 根据jti吊销token
+// @param string jti token的唯一标识
+// @param int64 exp token的过期时间，吊销记录保存到token过期为止，小于等于0时使用刷新token的有效期
+func (s *Service) Revoke(jti string, exp int64) error {
+	return s.store.Set(s.key("revoked", jti), "1", s.ttl(exp))
+}
+
+// 吊销整个token家族，即同一次登录后刷新出来的全部token
+func (s *Service) RevokeFamily(fid string) error {
+	if fid == "" {
+		return nil
+	}
+	return s.store.Set(s.key("family", fid), "1", time.Duration(s.conf.RefreshExpire)*time.Second)
+}
+
+// 吊销用户的全部token，即在所有设备上退出登录
+func (s *Service) RevokeUser(uid uint) error {
+	k := s.userKey(uid)
+	// 记录纳秒的吊销时间，吊销之后立即重新登录签发的token不受影响
+	return s.store.Set(k, strconv.FormatInt(time.Now().UnixNano(), 10), time.Duration(s.conf.RefreshExpire)*time.Second)
+}
+
+// 解密并校验token，不校验IP
+func (s *Service) parse(token string, aud string) (*util.JWT, *errors.Err) {
+	if token == "" {
+		return nil, errors.CodeMsg(errors.Unauthorized, "token not set")
+	}
+	tk := util.JWT{}
+	if err := util.DecryptJWT(token, s.conf.Secret, &tk); err != nil {
+		return nil, errors.CodeMsg(errors.Unauthorized, err.Error())
+	}
+	now := time.Now().Unix()
+	if tk.Aud != aud {
+		return nil, errors.CodeMsg(errors.Unauthorized, "token aud error")
+	}
+	if tk.Exp < now {
+		return nil, errors.CodeMsg(errors.Unauthorized, "token expired")
+	}
+	if tk.Nbf > now {
+		return nil, errors.CodeMsg(errors.Unauthorized, "token not effective")
+	}
+	if tk.UID < 1 {
+		return nil, errors.CodeMsg(errors.Unauthorized, "token uid error")
+	}
+	return &tk, nil
+}
+
+func (s *Service) refreshAud() string {
+	return s.conf.Aud + RefreshAudSuffix
+}
+
+func (s *Service) key(kind, id string) string {
+	return s.conf.Prefix + kind + ":" + id
+}
+
+// 用户吊销记录的key，包含aud，不同服务的同一个用户ID互不影响
+func (s *Service) userKey(uid uint) string {
+	return s.key("uid", s.conf.Aud+":"+strconv.FormatUint(uint64(uid), 10))
+}
+
+// 根据过期时间计算记录的保存时长
+func (s *Service) ttl(exp int64) time.Duration {
+	d := exp - time.Now().Unix()
+	if exp <= 0 || d > s.conf.RefreshExpire {
+		d = s.conf.RefreshExpire
+	}
+	if d < 1 {
+		d = 1
+	}
+	return time.Duration(d) * time.Second
+}
@@ -0,0 +1,254 @@
+package token
+
+import (
+	stderrors "errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/Mueat/frm-lib/cache"
+	"github.com/Mueat/frm-lib/errors"
+	"github.com/Mueat/frm-lib/util"
+)
+
+const testIP = "127.0.0.1"
+
+func newTestService(conf Config) *Service {
+	if conf.Secret == "" {
+		conf.Secret = "0123456789abcdef0123456789abcdef"
+	}
+	if conf.Aud == "" {
+		conf.Aud = util.JWT_AUD_USER
+	}
+	return New(conf, cache.NewMemory())
+}
+
+func assertCode(t *testing.T, err *errors.Err, code int) {
+	t.Helper()
+	if err == nil {
+		t.Fatalf("expected error code %d, got nil", code)
+	}
+	if err.Code != code {
+		t.Fatalf("expected error code %d, got %d (%s)", code, err.Code, err.Msg)
+	}
+}
+
+func TestIssueAndVerify(t *testing.T) {
+	s := newTestService(Config{})
+	pair := s.Issue(1, testIP, map[string]interface{}{"role": "admin"})
+	if pair.AccessToken == "" || pair.RefreshToken == "" {
+		t.Fatal("empty token issued")
+	}
+	if pair.ExpiresIn != DefaultAccessExpire || pair.RefreshExpiresIn != DefaultRefreshExpire {
+		t.Fatalf("unexpected expires: %d %d", pair.ExpiresIn, pair.RefreshExpiresIn)
+	}
+
+	tk, err := s.Verify(pair.AccessToken, testIP)
+	if err != nil {
+		t.Fatalf("verify access token: %v", err)
+	}
+	if tk.UID != 1 || tk.Fid == "" {
+		t.Fatalf("unexpected token: %+v", tk)
+	}
+	if _, err := s.Verify(pair.AccessToken, "10.0.0.1"); err == nil {
+		t.Fatal("access token should be bound to ip")
+	}
+	// 刷新token不能当做访问token使用
+	assertCode(t, func() *errors.Err { _, err := s.Verify(pair.RefreshToken, testIP); return err }(), errors.Unauthorized)
+	// 访问token不能用于刷新
+	assertCode(t, func() *errors.Err { _, err := s.Refresh(pair.AccessToken, testIP); return err }(), errors.Unauthorized)
+}
+
+func TestExpired(t *testing.T) {
+	s := newTestService(Config{})
+	access := util.GetJWT(util.JWTReq{Secret: s.conf.Secret, Expire: -10, UID: 1, Aud: s.conf.Aud, IP: testIP})
+	if _, err := s.Verify(access, testIP); err == nil {
+		t.Fatal("expired access token should be rejected")
+	}
+	refresh := util.GetJWT(util.JWTReq{Secret: s.conf.Secret, Expire: -10, UID: 1, Aud: s.refreshAud(), IP: testIP})
+	_, err := s.Refresh(refresh, testIP)
+	assertCode(t, err, errors.Unauthorized)
+	if err.Msg != "token expired" {
+		t.Fatalf("unexpected message: %s", err.Msg)
+	}
+}
+
+func TestRefreshRotation(t *testing.T) {
+	s := newTestService(Config{})
+	pair := s.Issue(1, testIP, nil)
+
+	next, err := s.Refresh(pair.RefreshToken, testIP)
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if next.RefreshToken == pair.RefreshToken || next.AccessToken == pair.AccessToken {
+		t.Fatal("refresh should issue new tokens")
+	}
+	first, _ := s.Verify(pair.AccessToken, testIP)
+	second, verr := s.Verify(next.AccessToken, testIP)
+	if verr != nil {
+		t.Fatalf("verify refreshed token: %v", verr)
+	}
+	if first.Fid != second.Fid {
+		t.Fatal("refreshed token should stay in the same family")
+	}
+
+	// 重复使用刷新token时吊销整个家族
+	_, err = s.Refresh(pair.RefreshToken, testIP)
+	assertCode(t, err, errors.Unauthorized)
+	if err.Msg != "token reused" {
+		t.Fatalf("unexpected message: %s", err.Msg)
+	}
+	if _, err := s.Verify(next.AccessToken, testIP); err == nil {
+		t.Fatal("family should be revoked after reuse")
+	}
+	if _, err := s.Refresh(next.RefreshToken, testIP); err == nil {
+		t.Fatal("refresh token of a revoked family should be rejected")
+	}
+
+	// 其他登录不受影响
+	other := s.Issue(1, testIP, nil)
+	if _, err := s.Verify(other.AccessToken, testIP); err != nil {
+		t.Fatalf("other family should be valid: %v", err)
+	}
+}
+
+func TestRefreshBindIP(t *testing.T) {
+	s := newTestService(Config{RefreshBindIP: true})
+	pair := s.Issue(1, testIP, nil)
+	if _, err := s.Refresh(pair.RefreshToken, "10.0.0.1"); err == nil {
+		t.Fatal("refresh token should be bound to ip")
+	}
+	if _, err := s.Refresh(pair.RefreshToken, testIP); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	s := newTestService(Config{})
+	pair := s.Issue(1, testIP, nil)
+	other := s.Issue(1, testIP, nil)
+
+	if err := s.RevokeToken(pair.AccessToken); err != nil {
+		t.Fatalf("revoke token: %v", err)
+	}
+	if _, err := s.Verify(pair.AccessToken, testIP); err == nil {
+		t.Fatal("revoked token should be rejected")
+	}
+	if _, err := s.Verify(other.AccessToken, testIP); err != nil {
+		t.Fatalf("other token should be valid: %v", err)
+	}
+
+	tk, _ := s.Verify(other.AccessToken, testIP)
+	if err := s.Revoke(tk.Jti, tk.Exp); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	if _, err := s.Verify(other.AccessToken, testIP); err == nil {
+		t.Fatal("revoked token should be rejected")
+	}
+}
+
+func TestRevokeUser(t *testing.T) {
+	s := newTestService(Config{})
+	pair := s.Issue(1, testIP, nil)
+	another := s.Issue(2, testIP, nil)
+
+	if err := s.RevokeUser(1); err != nil {
+		t.Fatalf("revoke user: %v", err)
+	}
+	if _, err := s.Verify(pair.AccessToken, testIP); err == nil {
+		t.Fatal("access token should be revoked")
+	}
+	if _, err := s.Refresh(pair.RefreshToken, testIP); err == nil {
+		t.Fatal("refresh token should be revoked")
+	}
+	if _, err := s.Verify(another.AccessToken, testIP); err != nil {
+		t.Fatalf("other user should not be affected: %v", err)
+	}
+
+	// 吊销后立即重新登录签发的token可以使用
+	relogin := s.Issue(1, testIP, nil)
+	if _, err := s.Verify(relogin.AccessToken, testIP); err != nil {
+		t.Fatalf("token issued after revoke should be valid: %v", err)
+	}
+	if _, err := s.Refresh(relogin.RefreshToken, testIP); err != nil {
+		t.Fatalf("refresh token issued after revoke should be valid: %v", err)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	s := newTestService(Config{})
+	s.Register()
+	pair := s.Issue(1, testIP, nil)
+	if _, err := util.VerifyJWT(s.conf.Secret, pair.AccessToken, s.conf.Aud, testIP); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	s.RevokeToken(pair.AccessToken)
+	if _, err := util.VerifyJWT(s.conf.Secret, pair.AccessToken, s.conf.Aud, testIP); err == nil {
+		t.Fatal("VerifyJWT should check revocation after Register")
+	}
+}
+
+// 记录调用次数，可以模拟存储出错
+type testStore struct {
+	cache.Store
+	gets   int32
+	getErr error
+}
+
+func (s *testStore) Get(k string) (string, error) {
+	atomic.AddInt32(&s.gets, 1)
+	if s.getErr != nil {
+		return "", s.getErr
+	}
+	return s.Store.Get(k)
+}
+
+func TestRevokeUserAud(t *testing.T) {
+	store := cache.NewMemory()
+	user := New(Config{Secret: "0123456789abcdef0123456789abcdef", Aud: util.JWT_AUD_USER}, store)
+	admin := New(Config{Secret: "0123456789abcdef0123456789abcdef", Aud: util.JWT_AUD_ADMIN}, store)
+	userPair := user.Issue(1, testIP, nil)
+	adminPair := admin.Issue(1, testIP, nil)
+
+	if err := admin.RevokeUser(1); err != nil {
+		t.Fatalf("revoke user: %v", err)
+	}
+	if _, err := admin.Verify(adminPair.AccessToken, testIP); err == nil {
+		t.Fatal("admin token should be revoked")
+	}
+	if _, err := user.Verify(userPair.AccessToken, testIP); err != nil {
+		t.Fatalf("user token of another aud should not be revoked: %v", err)
+	}
+
+	// 其他aud的token不检查吊销状态
+	tk, _ := user.Verify(userPair.AccessToken, testIP)
+	if err := admin.Check(tk); err != nil {
+		t.Fatalf("check should skip tokens of another aud: %v", err)
+	}
+}
+
+func TestCheckStoreError(t *testing.T) {
+	store := &testStore{Store: cache.NewMemory()}
+	s := New(Config{Secret: "0123456789abcdef0123456789abcdef", Aud: util.JWT_AUD_USER}, store)
+	pair := s.Issue(1, testIP, nil)
+	store.getErr = stderrors.New("store unavailable")
+	if _, err := s.Verify(pair.AccessToken, testIP); err == nil {
+		t.Fatal("verify should fail when the store is unavailable")
+	}
+	if _, err := s.Refresh(pair.RefreshToken, testIP); err == nil {
+		t.Fatal("refresh should fail when the store is unavailable")
+	}
+}
+
+func TestVerifyRegisteredChecksOnce(t *testing.T) {
+	store := &testStore{Store: cache.NewMemory()}
+	s := New(Config{Secret: "0123456789abcdef0123456789abcdef", Aud: "TEST_CHECK_ONCE"}, store)
+	s.Register()
+	pair := s.Issue(1, testIP, nil)
+	if _, err := s.Verify(pair.AccessToken, testIP); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if n := atomic.LoadInt32(&store.gets); n != 1 {
+		t.Fatalf("expected revocation to be checked once, got %d", n)
+	}
+}
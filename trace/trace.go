@@ -0,0 +1,39 @@
+package trace
+
+import (
+	"context"
+
+	"github.com/Mueat/frm-lib/util"
+)
+
+const (
+	// 传递请求ID的请求头
+	HeaderName = "X-Request-Id"
+)
+
+type ctxKey struct{}
+
+// 生成请求ID
+func NewID() string {
+	id := util.Uniqid("")
+	if nonce, err := util.GenerateNonce(8); err == nil {
+		id += nonce
+	}
+	return id
+}
+
+// 返回携带请求ID的context
+func NewContext(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, requestID)
+}
+
+// 从context中获取请求ID，不存在时返回空字符串
+func FromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	if id, ok := ctx.Value(ctxKey{}).(string); ok {
+		return id
+	}
+	return ""
+}
@@ -48,6 +48,8 @@ type AstFunc struct {
 	Method string
 	// 使用的中间件
 	MiddleWares []string
+	// 需要的权限
+	Perm string
 	// 请求地址
 	URL string
 	// 作用域
@@ -99,6 +101,8 @@ const (
 	AST_API_REQ = "request"
 	// 用于参数说明，如：@params id 用户ID
 	AST_API_PARAMS = "params"
+	// 用于定义访问需要的权限，如：@perm order.view
+	AST_API_PERM = "perm"
 
 	// 名称注解
 	AST_NAME = "name"
@@ -210,6 +214,10 @@ func ParseRouterFile(file string) (*AstFile, error) {
 				astFunc.ApiName = v[0]
 			case AST_API_MID:
 				astFunc.MiddleWares = v
+			case AST_API_PERM:
+				if len(v) > 0 {
+					astFunc.Perm = v[0]
+				}
 			case AST_API_GET:
 				astFunc.Method = "GET"
 				astFunc.URL = v[0]
@@ -340,7 +348,6 @@ func parseAstExprName(expr ast.Expr) string {
 	default:
 		return ""
 	}
-	return ""
 }
 
 // 解析注释
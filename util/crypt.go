@@ -295,6 +295,24 @@ func DecryptAES256GCM(aesKey, associatedData, nonce, ciphertext string) (plainte
 	return string(dataBytes), nil
 }
 
+// EncryptAES256GCM 使用 AEAD_AES_256_GCM 算法进行加密，与 DecryptAES256GCM 对应
+// 返回的密文使用base64编码
+func EncryptAES256GCM(aesKey, associatedData, nonce, plaintext string) (ciphertext string, err error) {
+	c, err := aes.NewCipher([]byte(aesKey))
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(c)
+	if err != nil {
+		return "", err
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return "", fmt.Errorf("nonce length must be %d", gcm.NonceSize())
+	}
+	dataBytes := gcm.Seal(nil, []byte(nonce), []byte(plaintext), []byte(associatedData))
+	return base64.StdEncoding.EncodeToString(dataBytes), nil
+}
+
 // SignSHA256WithRSA 通过私钥对字符串以 SHA256WithRSA 算法生成签名信息
 func SignSHA256WithRSA(source string, privateKey *rsa.PrivateKey) (signature string, err error) {
 	if privateKey == nil {
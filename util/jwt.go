@@ -2,19 +2,22 @@ package util
 
 import (
 	"errors"
+	"sync"
 	"time"
 )
 
 // JWT
 type JWT struct {
-	Aud   string      `json:"aud"`   //接收对象
-	Exp   int64       `json:"exp"`   //到期时间
-	Nbf   int64       `json:"nbf"`   //生效时间
-	Iat   int64       `json:"iat"`   //创建时间
-	Jti   string      `json:"jti"`   //token的唯一标识
-	IP    string      `json:"ip"`    //生成的IP地址
-	UID   uint        `json:"uid"`   // 用户ID
-	Extra interface{} `json:"extra"` // 其他数据
+	Aud   string      `json:"aud"`           //接收对象
+	Exp   int64       `json:"exp"`           //到期时间
+	Nbf   int64       `json:"nbf"`           //生效时间
+	Iat   int64       `json:"iat"`           //创建时间
+	IatNs int64       `json:"iat_ns"`        // 创建时间，单位：纳秒
+	Jti   string      `json:"jti"`           //token的唯一标识
+	IP    string      `json:"ip"`            //生成的IP地址
+	UID   uint        `json:"uid"`           // 用户ID
+	Fid   string      `json:"fid,omitempty"` // token所属的家族，同一次登录刷新出来的token属于同一家族
+	Extra interface{} `json:"extra"`         // 其他数据
 }
 
 // 生成JWT请求对象
@@ -25,9 +28,18 @@ type JWTReq struct {
 	Aud    string      // 接收对象
 	IP     string      // IP地址
 	Nbf    *time.Time  // 生效时间
+	Fid    string      // token家族
 	Extra  interface{} // 额外数据
 }
 
+// token校验钩子，返回错误则表示token不可用
+type JWTChecker func(tk *JWT) error
+
+var (
+	jwtCheckers   = make([]JWTChecker, 0)
+	jwtCheckersMu sync.RWMutex
+)
+
 // 常用AUD
 const (
 	JWT_AUD_USER    = "USER"    // 用户 aud
@@ -44,16 +56,22 @@ func GetJWT(req JWTReq) string {
 		timeNow := time.Now()
 		req.Nbf = &timeNow
 	}
-	now := time.Now().Unix()
+	timeNow := time.Now()
+	now := timeNow.Unix()
 	jti := Uniqid("tk")
+	if nonce, err := GenerateNonce(8); err == nil {
+		jti += nonce
+	}
 	token := JWT{
 		Aud:   req.Aud,
 		Exp:   now + req.Expire,
 		Nbf:   req.Nbf.Unix(),
 		Iat:   now,
+		IatNs: timeNow.UnixNano(),
 		Jti:   jti,
 		IP:    req.IP,
 		UID:   req.UID,
+		Fid:   req.Fid,
 		Extra: req.Extra,
 	}
 
@@ -90,6 +108,22 @@ func VerifyJWT(encryptSecret string, token string, aud string, ip string) (*JWT,
 	if tk.IP != ip {
 		return nil, errors.New("token ip error")
 	}
+	jwtCheckersMu.RLock()
+	checkers := jwtCheckers
+	jwtCheckersMu.RUnlock()
+	for _, checker := range checkers {
+		if err := checker(&tk); err != nil {
+			return nil, err
+		}
+	}
 
 	return &tk, nil
 }
+
+// 添加token校验钩子
+// VerifyJWT 在基础校验通过后会依次调用钩子，比如校验token是否已经被吊销
+func AddJWTChecker(checker JWTChecker) {
+	jwtCheckersMu.Lock()
+	defer jwtCheckersMu.Unlock()
+	jwtCheckers = append(jwtCheckers, checker)
+}
@@ -0,0 +1,71 @@
+package util
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 请求签名使用的请求头
+const (
+	SIGN_HEADER_APPID     = "X-App-Id"    // 调用方ID
+	SIGN_HEADER_TIMESTAMP = "X-Timestamp" // 秒级时间戳
+	SIGN_HEADER_NONCE     = "X-Nonce"     // 随机字符串
+	SIGN_HEADER_SIGNATURE = "X-Signature" // 签名
+)
+
+// 生成请求签名原文
+// 格式为：请求方法\n路径\n按key排序的查询参数\n请求体sha256\n时间戳\n随机字符串
+func SignCanonical(method string, path string, rawQuery string, body []byte, timestamp int64, nonce string) string {
+	query, _ := url.ParseQuery(rawQuery)
+	sum := sha256.Sum256(body)
+	return strings.Join([]string{
+		strings.ToUpper(method),
+		path,
+		query.Encode(),
+		hex.EncodeToString(sum[:]),
+		strconv.FormatInt(timestamp, 10),
+		nonce,
+	}, "\n")
+}
+
+// 生成请求签名
+// @param string secret 调用方秘钥
+func SignRequest(secret string, method string, path string, rawQuery string, body []byte, timestamp int64, nonce string) string {
+	return HMAC(sha256.New, SignCanonical(method, path, rawQuery, body, timestamp, nonce), secret)
+}
+
+// 对http请求签名，并设置签名相关的请求头
+// @param *http.Request req 要发送的请求
+// @param string appID 调用方ID
+// @param string secret 调用方秘钥
+func SignHTTPRequest(req *http.Request, appID string, secret string) error {
+	var body []byte
+	if req.Body != nil {
+		b, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return err
+		}
+		_ = req.Body.Close()
+		body = b
+		req.Body = ioutil.NopCloser(bytes.NewReader(b))
+	}
+	nonce, err := GenerateNonce(16)
+	if err != nil {
+		return err
+	}
+	timestamp := time.Now().Unix()
+	sign := SignRequest(secret, req.Method, req.URL.EscapedPath(), req.URL.RawQuery, body, timestamp, nonce)
+
+	req.Header.Set(SIGN_HEADER_APPID, appID)
+	req.Header.Set(SIGN_HEADER_TIMESTAMP, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SIGN_HEADER_NONCE, nonce)
+	req.Header.Set(SIGN_HEADER_SIGNATURE, sign)
+	return nil
+}
@@ -49,7 +49,7 @@ func Api(method string, action string, params interface{}, resp WeixinResponseIn
 	}
 
 	if resp.GetCode() != 0 {
-		log.Error().Str("lib", "weixin").Str("method", "api").Str("url", url).Interface("params", params).Int64("code", resp.GetCode()).Str("msg", resp.GetMsg()).Send()
+		log.Error().Str("lib", "weixin").Str("method", "api").Str("url", log.MaskText(url)).Interface("params", log.Mask(params)).Int64("code", resp.GetCode()).Str("msg", resp.GetMsg()).Send()
 		return errors.Msg(resp.GetMsg())
 	}
 	return nil